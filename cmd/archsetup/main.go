@@ -1,15 +1,21 @@
 package main
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/app"
 	"archsetup/internal/assert"
 	"archsetup/internal/dotfiles"
+	"archsetup/internal/events"
 	"archsetup/internal/github_auth"
+	"archsetup/internal/headless"
 	"archsetup/internal/menu"
 	"archsetup/internal/nvidia"
 	"archsetup/internal/profiles"
+	"archsetup/internal/resume"
+	"archsetup/internal/state"
 	"archsetup/internal/system"
 	"archsetup/internal/types"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -28,13 +34,31 @@ type TUIApp struct {
 	program *tea.Program
 }
 
+// HeadlessApp runs one of the plan/apply subcommands without the TUI.
+type HeadlessApp struct {
+	runner *headless.Runner
+	args   []string
+}
+
 type PanicCatchingModel struct {
 	Model tea.Model
 }
 
 func (app *TUIApp) Run() error {
-	_, err := app.program.Run()
-	return err
+	final, err := app.program.Run()
+	if err != nil {
+		return err
+	}
+	if f, ok := final.(interface{ Err() error }); ok && f.Err() != nil {
+		return f.Err()
+	}
+
+	println("Bye! To run this app again, run `bas-tui`")
+	return nil
+}
+
+func (app *HeadlessApp) Run() error {
+	return app.runner.Run(app.args)
 }
 
 func main() {
@@ -64,6 +88,8 @@ func main() {
 	}
 	defaultDotfilesPath := filepath.Join(home, "Developer", "dotfiles")
 
+	store := openStateStore()
+
 	githubAuthSvc := github_auth.NewDefaultService()
 	models := map[types.Phase]tea.Model{
 		types.MenuPhase:       menu.New(keys),
@@ -74,19 +100,99 @@ func main() {
 			defaultDotfilesPath,
 		),
 		types.NvidiaDriversPhase: nvidia.New(keys, nvidiaSvc),
-		types.ProfilesPhase:      profiles.New(keys, profilesSvc),
+		types.ProfilesPhase:      profiles.New(keys, profilesSvc, store),
+		types.ResumePhase:        resume.New(keys, store.Current()),
 	}
 
-	appModel := app.New(types.MenuPhase, models, keys)
+	var application Application
+	if len(os.Args) > 1 && headless.IsSubcommand(os.Args[1]) {
+		runner := headless.New(
+			&system.LiveExecutor{},
+			githubAuthSvc,
+			dotfilesSvc,
+			profilesSvc,
+			defaultDotfilesPath,
+			os.Stdout,
+			os.Stderr,
+		)
+		application = &HeadlessApp{runner: runner, args: os.Args[1:]}
+	} else {
+		flags, err := parseFlags(os.Args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(headless.ExitUsage)
+		}
 
-	wrappedModel := &PanicCatchingModel{Model: appModel}
-	program := tea.NewProgram(wrappedModel, tea.WithAltScreen())
-	tuiApp := &TUIApp{program: program}
+		opts := []tea.ProgramOption{tea.WithAltScreen()}
+		if flags.output == "json" {
+			// Keep stdout free for the NDJSON event stream.
+			events.SetOutput(os.Stdout)
+			opts = append(opts, tea.WithOutput(os.Stderr))
+		}
 
-	if err := run(os.Args, tuiApp); err != nil {
+		appModel := app.New(types.MenuPhase, models, keys).WithState(store)
+		if flags.answers != "" {
+			a, err := answers.Load(flags.answers)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(headless.ExitConfig)
+			}
+			appModel = appModel.WithAnswers(a)
+		}
+
+		wrappedModel := &PanicCatchingModel{Model: appModel}
+		program := tea.NewProgram(wrappedModel, opts...)
+		application = &TUIApp{program: program}
+	}
+
+	if err := run(os.Args, application); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		os.Exit(headless.ExitCode(err))
+	}
+}
+
+// openStateStore opens the saved progress of previous runs. A state file
+// that cannot be used only disables resuming.
+func openStateStore() *state.Store {
+	path, err := state.DefaultPath()
+	if err != nil {
+		log.Printf("state: %v", err)
+		return nil
+	}
+
+	store, err := state.Open(path)
+	if err != nil {
+		log.Printf("state: %v", err)
+		return nil
 	}
+
+	return store
+}
+
+// cliFlags are the flags accepted when running the TUI.
+type cliFlags struct {
+	output  string
+	answers string
+}
+
+func parseFlags(args []string) (cliFlags, error) {
+	var flags cliFlags
+
+	fs := flag.NewFlagSet("bas-tui", flag.ContinueOnError)
+	fs.StringVar(&flags.output, "output", "text", "output format: text or json (NDJSON events on stdout)")
+	fs.StringVar(&flags.answers, "answers", "", "path to a "+answers.FileName+" for an unattended run")
+
+	if err := fs.Parse(args); err != nil {
+		return cliFlags{}, err
+	}
+	if fs.NArg() > 0 {
+		return cliFlags{}, fmt.Errorf("unknown command: %s", fs.Arg(0))
+	}
+	if flags.output != "text" && flags.output != "json" {
+		return cliFlags{}, fmt.Errorf("--output must be text or json, got %q", flags.output)
+	}
+
+	return flags, nil
 }
 
 func run(args []string, app Application) (err error) {
@@ -113,8 +219,6 @@ func run(args []string, app Application) (err error) {
 		return fmt.Errorf("application error: %w", err)
 	}
 
-	println("Bye! To run this app again, run `bas-tui`")
-
 	return nil
 }
 
@@ -140,6 +244,15 @@ func (m *PanicCatchingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// Err returns the error the wrapped model stopped with, if it keeps one.
+func (m *PanicCatchingModel) Err() error {
+	if f, ok := m.Model.(interface{ Err() error }); ok {
+		return f.Err()
+	}
+
+	return nil
+}
+
 func (m *PanicCatchingModel) View() string {
 	defer func() {
 		if r := recover(); r != nil {
@@ -94,3 +94,38 @@ func TestSetupLogging(t *testing.T) {
 		}
 	})
 }
+
+func TestParseFlags(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it defaults to text output", func(t *testing.T) {
+		flags, err := parseFlags(nil)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if flags.output != "text" {
+			t.Errorf("expected text output, got %q", flags.output)
+		}
+	})
+
+	t.Run("it accepts json output", func(t *testing.T) {
+		flags, err := parseFlags([]string{"--output=json"})
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if flags.output != "json" {
+			t.Errorf("expected json output, got %q", flags.output)
+		}
+	})
+
+	t.Run("it rejects unknown formats and arguments", func(t *testing.T) {
+		if _, err := parseFlags([]string{"--output=yaml"}); err == nil {
+			t.Error("expected an error for an unknown output format")
+		}
+		if _, err := parseFlags([]string{"deploy"}); err == nil {
+			t.Error("expected an error for an unknown command")
+		}
+	})
+}
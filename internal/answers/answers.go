@@ -0,0 +1,60 @@
+package answers
+
+import (
+	"archsetup/internal/utils"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the conventional name of the answer file.
+const FileName = "bas-answers.toml"
+
+// Answers preseeds every confirmation of an unattended run.
+type Answers struct {
+	Repo           string `toml:"repo"`
+	Dest           string `toml:"dest"`
+	Profile        string `toml:"profile"`
+	InstallNvidia  bool   `toml:"install_nvidia"`
+	RunPostInstall bool   `toml:"run_post_install"`
+}
+
+// LoadedMsg hands the answers to the phase models at startup.
+type LoadedMsg struct {
+	Answers Answers
+}
+
+// ClearedMsg tells the phase models to stop answering for the user, when an
+// unattended run is handed back to them.
+type ClearedMsg struct{}
+
+// Load reads and validates an answer file.
+func Load(path string) (Answers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Answers{}, fmt.Errorf("could not read answer file: %w", err)
+	}
+
+	var a Answers
+	if err := toml.Unmarshal(data, &a); err != nil {
+		return Answers{}, fmt.Errorf("invalid %s format: %w", path, err)
+	}
+
+	a.Repo = strings.TrimSpace(a.Repo)
+	a.Profile = strings.TrimSpace(a.Profile)
+	if a.Repo == "" {
+		return Answers{}, fmt.Errorf("%s: repo is required", path)
+	}
+	if a.Profile == "" {
+		return Answers{}, fmt.Errorf("%s: profile is required", path)
+	}
+
+	a.Dest, err = utils.ExpandHome(strings.TrimSpace(a.Dest))
+	if err != nil {
+		return Answers{}, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,59 @@
+package answers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAnswers(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("could not write answer file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("it loads a complete answer file", func(t *testing.T) {
+		path := writeAnswers(t, `
+repo = "user/dotfiles"
+dest = "/opt/dotfiles"
+profile = "Arch Desktop"
+install_nvidia = true
+run_post_install = true
+`)
+
+		a, err := Load(path)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := Answers{
+			Repo:           "user/dotfiles",
+			Dest:           "/opt/dotfiles",
+			Profile:        "Arch Desktop",
+			InstallNvidia:  true,
+			RunPostInstall: true,
+		}
+		if a != want {
+			t.Errorf("Load() = %+v, want %+v", a, want)
+		}
+	})
+
+	t.Run("it requires a repo and a profile", func(t *testing.T) {
+		path := writeAnswers(t, `dest = "/opt/dotfiles"`)
+
+		if _, err := Load(path); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("it returns an error for a missing file", func(t *testing.T) {
+		if _, err := Load(filepath.Join(t.TempDir(), "nope.toml")); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
@@ -1,17 +1,23 @@
 package app
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/assert"
 	"archsetup/internal/dotfiles"
+	"archsetup/internal/events"
 	"archsetup/internal/github"
 	"archsetup/internal/layout"
 	"archsetup/internal/menu"
 	"archsetup/internal/navigator"
 	"archsetup/internal/nvidia"
 	"archsetup/internal/profiles"
+	"archsetup/internal/resume"
+	"archsetup/internal/state"
 	"archsetup/internal/styles"
 	"archsetup/internal/types"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -24,6 +30,20 @@ type model struct {
 	width        int
 	keys         types.KeyMap
 	dotfilesPath string
+
+	// Unattended runs walk through the phases on their own, driven by an
+	// answer file.
+	answers       *answers.Answers
+	started       bool
+	authenticated bool
+	hasNvidiaGpu  bool
+	completed     map[types.Phase]bool
+
+	// store persists progress so an interrupted run can be resumed.
+	store *state.Store
+
+	// err is why an unattended run stopped.
+	err error
 }
 
 func New(
@@ -32,12 +52,27 @@ func New(
 	keys types.KeyMap,
 ) *model {
 	return &model{
-		keys:   keys,
-		nav:    navigator.New(initialPhase),
-		models: models,
+		keys:      keys,
+		nav:       navigator.New(initialPhase),
+		models:    models,
+		completed: map[types.Phase]bool{},
 	}
 }
 
+// WithAnswers turns the run into an unattended one: phases are opened in
+// order and every confirmation is answered from the answer file.
+func (m *model) WithAnswers(a answers.Answers) *model {
+	m.answers = &a
+	return m
+}
+
+// WithState restores the progress saved by a previous run and records the
+// progress of this one.
+func (m *model) WithState(store *state.Store) *model {
+	m.store = store
+	return m
+}
+
 func (m *model) Init() tea.Cmd {
 	log.Printf("app: Init received")
 
@@ -51,9 +86,19 @@ func (m *model) Init() tea.Cmd {
 		nvidiaModel.CheckGpuCmd(),
 	)
 
+	var cmds []tea.Cmd
+	if m.answers != nil {
+		loaded := answers.LoadedMsg{Answers: *m.answers}
+		for phase := range m.models {
+			m.updateAndCollectCmd(phase, loaded, &cmds)
+		}
+	}
+	m.restoreState(&cmds)
+
 	return tea.Batch(
 		m.models[m.nav.Current()].Init(),
 		initialChecks,
+		tea.Batch(cmds...),
 	)
 }
 
@@ -68,12 +113,25 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case github.AuthStatusMsg:
 		return m.handleGithubAuthStatusMsg(msg)
 
+	case nvidia.GpuCheckResultMsg:
+		m.hasNvidiaGpu = msg.HasNvidiaGpu
+		return m.delegateToActive(msg)
+
 	case types.PhaseFinished:
 		return m.handlePhaseFinished(msg)
 
 	case types.PhaseCancelled:
 		return m.handlePhaseCancelled()
 
+	case types.PhaseFailed:
+		return m.handlePhaseFailed(msg)
+
+	case resume.AcceptedMsg:
+		return m.handleResumeAcceptedMsg(msg)
+
+	case resume.DeclinedMsg:
+		return m.handleResumeDeclinedMsg()
+
 	case dotfiles.DotfilesFinished:
 		return m.handleDotFilesFinishedMsg(msg)
 
@@ -126,12 +184,19 @@ func (m *model) handleGithubAuthStatusMsg(
 
 	var cmds []tea.Cmd
 
+	m.authenticated = msg.IsAuthenticated
+
 	m.updateAndCollectCmd(types.DotfilesPhase, msg, &cmds)
 
 	var activeCmd tea.Cmd
 	_, activeCmd = m.delegateToActive(msg)
 	cmds = append(cmds, activeCmd)
 
+	if m.answers != nil && !m.started {
+		m.started = true
+		cmds = append(cmds, m.advanceUnattended())
+	}
+
 	return m, tea.Batch(cmds...)
 }
 
@@ -139,6 +204,9 @@ func (m *model) handlePhaseFinished(msg types.PhaseFinished) (tea.Model, tea.Cmd
 	log.Println("app: PhaseFinishedMsg received")
 
 	finished := m.nav.Current()
+	events.Phase(events.PhaseFinished, finished.String())
+	m.completed[finished] = true
+	m.store.Update(func(s *state.State) { s.CompletePhase(finished) })
 
 	var cmds []tea.Cmd
 
@@ -148,14 +216,41 @@ func (m *model) handlePhaseFinished(msg types.PhaseFinished) (tea.Model, tea.Cmd
 		&cmds,
 	)
 
-	cmds = append(cmds, m.popNavAndInit())
+	cmds = append(cmds, m.popNavAndInit(), m.advanceUnattended())
 	return m, tea.Batch(cmds...)
 }
 
+// handlePhaseFailed ends an unattended run, keeping the error for the exit
+// status.
+func (m *model) handlePhaseFailed(msg types.PhaseFailed) (tea.Model, tea.Cmd) {
+	failed := m.nav.Current()
+	log.Printf("app: phase %v failed: %v", failed, msg.Err)
+	events.Failure(failed.String(), msg.Err)
+
+	m.err = fmt.Errorf("%s failed: %w", failed, msg.Err)
+	return m.handleQuit()
+}
+
+// Err returns why an unattended run stopped, or nil.
+func (m *model) Err() error {
+	return m.err
+}
+
 func (m *model) handlePhaseCancelled() (tea.Model, tea.Cmd) {
 	log.Println("app: PhaseCancelledMsg received")
+	events.Phase(events.PhaseCancelled, m.nav.Current().String())
 
-	return m, m.popNavAndInit()
+	var cmds []tea.Cmd
+	if m.answers != nil {
+		log.Println("app: phase cancelled, handing an unattended run back to the user")
+		m.answers = nil
+		for phase := range m.models {
+			m.updateAndCollectCmd(phase, answers.ClearedMsg{}, &cmds)
+		}
+	}
+
+	cmds = append(cmds, m.popNavAndInit())
+	return m, tea.Batch(cmds...)
 }
 
 func (m *model) handleDotFilesFinishedMsg(
@@ -167,6 +262,12 @@ func (m *model) handleDotFilesFinishedMsg(
 	var cmds []tea.Cmd
 
 	m.dotfilesPath = msg.Path
+	events.Phase(events.PhaseFinished, types.DotfilesPhase.String())
+	m.completed[types.DotfilesPhase] = true
+	m.store.Update(func(s *state.State) {
+		s.CompletePhase(types.DotfilesPhase)
+		s.DotfilesPath = msg.Path
+	})
 
 	m.updateAndCollectCmd(
 		types.ProfilesPhase,
@@ -187,11 +288,128 @@ func (m *model) handleDotFilesFinishedMsg(
 	)
 
 	cmd = m.popNavAndInit()
-	cmds = append(cmds, cmd)
+	cmds = append(cmds, cmd, m.advanceUnattended())
 
 	return m, tea.Batch(cmds...)
 }
 
+// restoreState carries the phases and dotfiles path of a previous run over
+// into the menu and offers to resume an unfinished profile installation.
+func (m *model) restoreState(cmds *[]tea.Cmd) {
+	if m.store == nil {
+		return
+	}
+
+	st := m.store.Current()
+	for _, phase := range st.CompletedPhases {
+		// Authentication is checked again on every launch.
+		if phase == types.GithubAuthPhase {
+			continue
+		}
+		m.updateAndCollectCmd(types.MenuPhase, menu.PhaseDoneMsg{Phase: phase}, cmds)
+	}
+
+	if st.DotfilesPath != "" {
+		m.dotfilesPath = st.DotfilesPath
+		m.updateAndCollectCmd(
+			types.ProfilesPhase,
+			profiles.DotfilesPathUpdatedMsg{Path: st.DotfilesPath},
+			cmds,
+		)
+		m.updateAndCollectCmd(
+			types.MenuPhase,
+			menu.DotfilesPathUpdatedMsg{Path: st.DotfilesPath},
+			cmds,
+		)
+	}
+
+	if !st.Resumable() {
+		return
+	}
+
+	log.Printf("app: unfinished install of profile %q found", st.Profile)
+
+	// Only an unfinished run lets unattended runs skip phases; a finished
+	// one is applied again from the start.
+	for _, phase := range st.CompletedPhases {
+		if phase != types.GithubAuthPhase {
+			m.completed[phase] = true
+		}
+	}
+
+	// Unattended runs resume without asking, as long as they install the
+	// same profile.
+	if m.answers != nil {
+		if strings.EqualFold(st.Profile, m.answers.Profile) {
+			m.updateAndCollectCmd(types.ProfilesPhase, resumeMsg(st), cmds)
+		}
+		return
+	}
+
+	if _, ok := m.models[types.ResumePhase]; ok {
+		m.nav.Push(types.ResumePhase)
+	}
+}
+
+func (m *model) handleResumeAcceptedMsg(
+	msg resume.AcceptedMsg,
+) (tea.Model, tea.Cmd) {
+	log.Printf("app: resuming profile %q", msg.State.Profile)
+
+	var cmds []tea.Cmd
+	m.updateAndCollectCmd(types.ProfilesPhase, resumeMsg(msg.State), &cmds)
+
+	m.nav.Pop()
+	cmds = append(cmds, func() tea.Msg {
+		return types.MenuItemSelected{Phase: types.ProfilesPhase}
+	})
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *model) handleResumeDeclinedMsg() (tea.Model, tea.Cmd) {
+	log.Println("app: previous run discarded")
+
+	m.store.Update(func(s *state.State) {
+		s.Profile = ""
+		s.PackageCount = 0
+		s.Packages = nil
+		s.Deselected = nil
+	})
+
+	return m, m.popNavAndInit()
+}
+
+func resumeMsg(st state.State) profiles.ResumeMsg {
+	return profiles.ResumeMsg{Profile: st.Profile, Packages: st.Packages, Deselected: st.Deselected}
+}
+
+// advanceUnattended opens the next unfinished phase of an unattended run.
+func (m *model) advanceUnattended() tea.Cmd {
+	if m.answers == nil {
+		return nil
+	}
+
+	var next types.Phase
+	switch {
+	case !m.authenticated && !m.completed[types.GithubAuthPhase]:
+		next = types.GithubAuthPhase
+	case !m.completed[types.DotfilesPhase]:
+		next = types.DotfilesPhase
+	case m.hasNvidiaGpu && m.answers.InstallNvidia &&
+		!m.completed[types.NvidiaDriversPhase]:
+		next = types.NvidiaDriversPhase
+	case !m.completed[types.ProfilesPhase]:
+		next = types.ProfilesPhase
+	default:
+		log.Println("app: unattended run complete")
+		return nil
+	}
+
+	log.Printf("app: unattended run opening phase %v", next)
+	return func() tea.Msg { return types.MenuItemSelected{Phase: next} }
+}
+
 func (m *model) popNavAndInit() tea.Cmd {
 	log.Println("app: popNavigatorAndInit received")
 	m.nav.Pop()
@@ -1,11 +1,19 @@
 package app
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/dotfiles"
+	"archsetup/internal/events"
 	"archsetup/internal/github"
 	"archsetup/internal/menu"
 	"archsetup/internal/profiles"
+	"archsetup/internal/resume"
+	"archsetup/internal/state"
 	"archsetup/internal/types"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -198,3 +206,202 @@ func TestAppModel_KeyMsg_HandlesQuit(t *testing.T) {
 		t.Error("expected tea.Quit command but got something else")
 	}
 }
+
+func TestAppModel_PhaseFinished_EmitsEvent(t *testing.T) {
+	// ARRANGE
+	var buf bytes.Buffer
+	events.SetOutput(&buf)
+	defer events.SetOutput(nil)
+
+	m, _ := setupTestModel()
+	m.nav.Push(types.NvidiaDriversPhase)
+
+	// ACT
+	m.Update(types.PhaseFinished{})
+
+	// ASSERT
+	var ev events.Event
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatalf("expected a JSON event, got %q: %v", buf.String(), err)
+	}
+	if ev.Type != events.PhaseFinished || ev.Phase != "nvidia_drivers" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestAppModel_PhaseFailed_StopsTheRun(t *testing.T) {
+	// ARRANGE
+	var buf bytes.Buffer
+	events.SetOutput(&buf)
+	defer events.SetOutput(nil)
+
+	m, _ := setupTestModel()
+	m = m.WithAnswers(answers.Answers{Repo: "u/dots", Profile: "Desk"})
+	m.nav.Push(types.NvidiaDriversPhase)
+	installErr := errors.New("pacman failed")
+
+	// ACT
+	_, cmd := m.Update(types.PhaseFailed{Err: installErr})
+
+	// ASSERT
+	if !errors.Is(m.Err(), installErr) {
+		t.Errorf("expected the run to keep the install error, got %v", m.Err())
+	}
+	if cmd == nil {
+		t.Fatal("expected the run to quit, got no command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Errorf("expected the run to quit, got %T", cmd())
+	}
+	var ev events.Event
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatalf("expected a JSON event, got %q: %v", buf.String(), err)
+	}
+	if ev.Type != events.PhaseFailed || ev.Phase != "nvidia_drivers" || ev.Error != "pacman failed" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestAppModel_Unattended_OpensPhasesInOrder(t *testing.T) {
+	// ARRANGE
+	m, _ := setupTestModel()
+	m = m.WithAnswers(answers.Answers{Repo: "u/dots", Profile: "Desk"})
+
+	// ACT
+	_, cmd := m.Update(github.AuthStatusMsg{IsAuthenticated: true})
+
+	// ASSERT
+	if !batchContains(cmd, types.MenuItemSelected{Phase: types.DotfilesPhase}) {
+		t.Fatal("expected the dotfiles phase to be opened")
+	}
+
+	m.Update(types.MenuItemSelected{Phase: types.DotfilesPhase})
+	_, cmd = m.Update(dotfiles.DotfilesFinished{Path: "/dots"})
+
+	if !batchContains(cmd, types.MenuItemSelected{Phase: types.ProfilesPhase}) {
+		t.Error("expected the profiles phase to be opened once dotfiles finished")
+	}
+}
+
+func TestAppModel_PhaseCancelled_ClearsAnswers(t *testing.T) {
+	// ARRANGE
+	m, mockModels := setupTestModel()
+	m = m.WithAnswers(answers.Answers{Repo: "u/dots", Profile: "Desk"})
+	m.nav.Push(types.ProfilesPhase)
+
+	// ACT
+	m.Update(types.PhaseCancelled{})
+
+	// ASSERT
+	if m.answers != nil {
+		t.Error("expected the app to stop answering")
+	}
+	for phase, modelInstance := range mockModels {
+		mock := modelInstance.(*mockModel)
+		if _, ok := mock.lastMsgReceived.(answers.ClearedMsg); !ok && phase != types.MenuPhase {
+			t.Errorf("model for phase %v did not receive answers.ClearedMsg", phase)
+		}
+	}
+}
+
+func TestAppModel_RestoreState_OffersResume(t *testing.T) {
+	// ARRANGE
+	store, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("could not open state: %v", err)
+	}
+	store.Update(func(s *state.State) {
+		s.CompletePhase(types.DotfilesPhase)
+		s.DotfilesPath = "/dots"
+		s.StartProfile("Desk")
+	})
+
+	m, mocks := setupTestModel()
+	mocks[types.ResumePhase] = &mockModel{}
+	m = m.WithState(store)
+
+	// ACT
+	var cmds []tea.Cmd
+	m.restoreState(&cmds)
+
+	// ASSERT
+	if m.nav.Current() != types.ResumePhase {
+		t.Errorf("expected the resume prompt, got phase %v", m.nav.Current())
+	}
+	if !m.completed[types.DotfilesPhase] || m.dotfilesPath != "/dots" {
+		t.Error("expected the dotfiles phase and path to be restored")
+	}
+	profilesMock := mocks[types.ProfilesPhase].(*mockModel)
+	if _, ok := profilesMock.lastMsgReceived.(profiles.DotfilesPathUpdatedMsg); !ok {
+		t.Errorf("expected profiles to receive the dotfiles path, got %T", profilesMock.lastMsgReceived)
+	}
+}
+
+func TestAppModel_ResumeAccepted_OpensProfiles(t *testing.T) {
+	// ARRANGE
+	m, mocks := setupTestModel()
+	mocks[types.ResumePhase] = &mockModel{}
+	m.nav.Push(types.ResumePhase)
+	st := state.State{Profile: "Desk", Packages: map[string]string{"git": state.PackageOK}}
+
+	// ACT
+	_, cmd := m.Update(resume.AcceptedMsg{State: st})
+
+	// ASSERT
+	profilesMock := mocks[types.ProfilesPhase].(*mockModel)
+	got, ok := profilesMock.lastMsgReceived.(profiles.ResumeMsg)
+	if !ok || got.Profile != "Desk" || got.Packages["git"] != state.PackageOK {
+		t.Errorf("expected profiles to receive the run to resume, got %+v", profilesMock.lastMsgReceived)
+	}
+	if m.nav.Current() != types.MenuPhase {
+		t.Errorf("expected the resume prompt to be closed, got phase %v", m.nav.Current())
+	}
+	if !batchContains(cmd, types.MenuItemSelected{Phase: types.ProfilesPhase}) {
+		t.Error("expected the profiles phase to be opened")
+	}
+}
+
+func TestAppModel_DotfilesFinished_PersistsState(t *testing.T) {
+	// ARRANGE
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := state.Open(path)
+	if err != nil {
+		t.Fatalf("could not open state: %v", err)
+	}
+	m, _ := setupTestModel()
+	m = m.WithState(store)
+	m.nav.Push(types.DotfilesPhase)
+
+	// ACT
+	m.Update(dotfiles.DotfilesFinished{Path: "/dots"})
+
+	// ASSERT
+	reopened, err := state.Open(path)
+	if err != nil {
+		t.Fatalf("could not reopen state: %v", err)
+	}
+	st := reopened.Current()
+	if !st.IsCompleted(types.DotfilesPhase) || st.DotfilesPath != "/dots" {
+		t.Errorf("expected the dotfiles phase to be saved, got %+v", st)
+	}
+}
+
+// batchContains runs cmd (recursing into batches) and reports whether any of
+// the produced messages equals want.
+func batchContains(cmd tea.Cmd, want tea.Msg) bool {
+	if cmd == nil {
+		return false
+	}
+
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, c := range batch {
+			if batchContains(c, want) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return msg == want
+}
@@ -32,7 +32,9 @@ type Service struct {
 	fs   system.FileSystem
 }
 
-var errDestDirExists = errors.New(
+// ErrDestDirExists is returned by CheckDestIsValid when the destination
+// already holds files, usually a previous clone of the dotfiles.
+var ErrDestDirExists = errors.New(
 	"destination directory already exists and is not empty",
 )
 
@@ -105,7 +107,7 @@ func (s *Service) CheckDestIsValid(destPath string) error {
 
 	if len(entries) > 0 {
 		log.Printf("dotfiles: destination path is not empty")
-		return errDestDirExists
+		return ErrDestDirExists
 	}
 
 	return nil
@@ -133,7 +135,7 @@ func (s *Service) ValidateCmd(repo, dest string) tea.Cmd {
 
 		var destExists bool
 		for err := range errs {
-			if errors.Is(err, errDestDirExists) {
+			if errors.Is(err, ErrDestDirExists) {
 				destExists = true
 				continue
 			}
@@ -150,19 +152,22 @@ func (s *Service) ValidateCmd(repo, dest string) tea.Cmd {
 	}
 }
 
+// CloneRepo clones the GitHub repository over SSH into dest.
+func (s *Service) CloneRepo(repo, dest string) error {
+	url := fmt.Sprintf("git@github.com:%s.git", repo)
+	cmd := exec.Command("git", "clone", url, dest)
+
+	if err := s.exec.Run(cmd); err != nil {
+		return fmt.Errorf("Failed to clone repo: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Service) CloneRepoCmd(repo, dest string) tea.Cmd {
 	log.Printf("dotfiles: cloning repo to destination")
 
 	return func() tea.Msg {
-		url := fmt.Sprintf("git@github.com:%s.git", repo)
-		cmd := exec.Command("git", "clone", url, dest)
-
-		if err := s.exec.Run(cmd); err != nil {
-			return cloneResultMsg{
-				err: fmt.Errorf("Failed to clone repo: %w", err),
-			}
-		}
-
-		return cloneResultMsg{err: nil}
+		return cloneResultMsg{err: s.CloneRepo(repo, dest)}
 	}
 }
@@ -240,8 +240,8 @@ func TestCheckDestIsValid_PathExistsAndIsNotEmpty(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected an error, but got nil")
 	}
-	if !errors.Is(err, errDestDirExists) {
-		t.Errorf("expected error to be errDestDirExists, but got %v", err)
+	if !errors.Is(err, ErrDestDirExists) {
+		t.Errorf("expected error to be ErrDestDirExists, but got %v", err)
 	}
 }
 
@@ -1,6 +1,7 @@
 package dotfiles
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/assert"
 	"archsetup/internal/github"
 	"archsetup/internal/navigator"
@@ -46,6 +47,10 @@ type Model struct {
 	height       int
 	service      *Service
 	err          error
+
+	// answers is set for unattended runs; every confirmation is taken
+	// automatically.
+	answers *answers.Answers
 }
 
 func New(
@@ -80,6 +85,10 @@ func New(
 func (m *Model) Init() tea.Cmd {
 	m.nav.Reset(inputPhase)
 
+	if m.answers != nil {
+		return m.submit()
+	}
+
 	return textinput.Blink
 }
 
@@ -99,6 +108,13 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case github.AuthStatusMsg:
 		return m.handleGithubAuthStatusMsg(msg)
 
+	case answers.LoadedMsg:
+		return m.handleAnswersLoadedMsg(msg)
+
+	case answers.ClearedMsg:
+		m.answers = nil
+		return m, nil
+
 	case validationResultMsg:
 		return m.handleValidationResultMsg(msg)
 
@@ -126,7 +142,20 @@ func (m *Model) handleGithubAuthStatusMsg(
 	msg github.AuthStatusMsg,
 ) (tea.Model, tea.Cmd) {
 	m.Username = msg.Username
-	m.repoInput.SetValue(fmt.Sprintf("%s/dotfiles", m.Username))
+	if m.answers == nil {
+		m.repoInput.SetValue(fmt.Sprintf("%s/dotfiles", m.Username))
+	}
+	return m, nil
+}
+
+func (m *Model) handleAnswersLoadedMsg(
+	msg answers.LoadedMsg,
+) (tea.Model, tea.Cmd) {
+	m.answers = &msg.Answers
+	m.repoInput.SetValue(msg.Answers.Repo)
+	if msg.Answers.Dest != "" {
+		m.destInput.SetValue(msg.Answers.Dest)
+	}
 	return m, nil
 }
 
@@ -146,6 +175,13 @@ func (m *Model) handleValidationResultMsg(
 
 	m.nav.Push(nextPhase)
 
+	if m.answers != nil {
+		if msg.DirAlreadyExists {
+			return m, m.finish()
+		}
+		return m, m.startClone()
+	}
+
 	return m, nil
 }
 
@@ -158,6 +194,11 @@ func (m *Model) handleCloneResultMsg(
 		return m, nil
 	}
 	m.nav.Push(cloneCompletePhase)
+
+	if m.answers != nil {
+		return m, m.finish()
+	}
+
 	return m, nil
 }
 
@@ -187,19 +228,7 @@ func (m *Model) handleInputKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	switch {
 	case key.Matches(msg, m.keys.Enter):
-		repoPath := m.repoPath()
-		destPath := m.destPath()
-
-		if repoPath == "" || destPath == "" || strings.HasSuffix(repoPath, "/") {
-			m.err = errors.New("paths cannot be empty or incomplete")
-			return m, nil
-		}
-		m.err = nil
-		m.nav.Push(verifyingPhase)
-		return m, tea.Batch(
-			m.spinner.Tick,
-			m.service.ValidateCmd(repoPath, destPath),
-		)
+		return m, m.submit()
 
 	case key.Matches(msg, m.keys.Back):
 		return m.previousPhase()
@@ -252,13 +281,7 @@ func (m *Model) handleConfirmationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.nav.Reset(inputPhase)
 		return m, nil
 	case key.Matches(msg, m.keys.Enter):
-		m.nav.Push(cloningPhase)
-		repoPath := strings.TrimSpace(m.repoInput.Value())
-		destPath := strings.TrimSpace(m.destInput.Value())
-		return m, tea.Batch(
-			m.spinner.Tick,
-			m.service.CloneRepoCmd(repoPath, destPath),
-		)
+		return m, m.startClone()
 	}
 
 	return m, nil
@@ -267,7 +290,7 @@ func (m *Model) handleConfirmationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m *Model) handleCloneCompleteKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, m.keys.Enter):
-		return m, func() tea.Msg { return DotfilesFinished{Path: m.destPath()} }
+		return m, m.finish()
 
 	case key.Matches(msg, m.keys.Back):
 		m.nav.Reset(inputPhase)
@@ -277,6 +300,35 @@ func (m *Model) handleCloneCompleteKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// submit validates the repo and destination inputs.
+func (m *Model) submit() tea.Cmd {
+	repoPath := m.repoPath()
+	destPath := m.destPath()
+
+	if repoPath == "" || destPath == "" || strings.HasSuffix(repoPath, "/") {
+		m.err = errors.New("paths cannot be empty or incomplete")
+		return nil
+	}
+	m.err = nil
+	m.nav.Push(verifyingPhase)
+	return tea.Batch(
+		m.spinner.Tick,
+		m.service.ValidateCmd(repoPath, destPath),
+	)
+}
+
+func (m *Model) startClone() tea.Cmd {
+	m.nav.Push(cloningPhase)
+	return tea.Batch(
+		m.spinner.Tick,
+		m.service.CloneRepoCmd(m.repoPath(), m.destPath()),
+	)
+}
+
+func (m *Model) finish() tea.Cmd {
+	return func() tea.Msg { return DotfilesFinished{Path: m.destPath()} }
+}
+
 func (m *Model) destPath() string {
 	return strings.TrimSpace(m.destInput.Value())
 }
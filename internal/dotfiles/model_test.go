@@ -1,6 +1,7 @@
 package dotfiles
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/github"
 	"archsetup/internal/types"
 	"errors"
@@ -488,3 +489,33 @@ func TestUpdate_BackOnDirExistsPhase_ResetsToInput(t *testing.T) {
 		t.Errorf("expected phase to be %v, but got %v", inputPhase, m.nav.Current())
 	}
 }
+
+func TestUpdate_AnswersLoaded_RunsUnattended(t *testing.T) {
+	// Arrange
+	m := setupTestModel()
+	loaded := answers.LoadedMsg{Answers: answers.Answers{
+		Repo: "someone/dots",
+		Dest: "/srv/dots",
+	}}
+
+	// Act
+	m.Update(loaded)
+	m.Update(github.AuthStatusMsg{IsAuthenticated: true, Username: "other"})
+	initCmd := m.Init()
+
+	// Assert
+	if m.repoPath() != "someone/dots" || m.destPath() != "/srv/dots" {
+		t.Errorf("expected inputs from the answer file, got %q and %q", m.repoPath(), m.destPath())
+	}
+	if initCmd == nil || m.nav.Current() != verifyingPhase {
+		t.Fatalf("expected Init to start verifying, got phase %v", m.nav.Current())
+	}
+
+	_, cmd := m.Update(validationResultMsg{DirAlreadyExists: true})
+	if cmd == nil {
+		t.Fatal("expected a command but got nil")
+	}
+	if msg, ok := cmd().(DotfilesFinished); !ok || msg.Path != "/srv/dots" {
+		t.Errorf("expected DotfilesFinished for /srv/dots, got %#v", msg)
+	}
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Type identifies what an Event reports.
+type Type string
+
+const (
+	PhaseFinished     Type = "phase_finished"
+	PhaseCancelled    Type = "phase_cancelled"
+	PhaseFailed       Type = "phase_failed"
+	PackageResult     Type = "package_result"
+	StowResult        Type = "stow_result"
+	DefaultsResult    Type = "defaults_result"
+	PostInstallResult Type = "post_install_result"
+)
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Event is a single line of the newline-delimited JSON stream.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    Type      `json:"type"`
+	Phase   string    `json:"phase,omitempty"`
+	Package string    `json:"package,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Emitter writes events as NDJSON. A nil writer discards everything.
+type Emitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+func New(w io.Writer) *Emitter {
+	e := &Emitter{now: time.Now}
+	e.SetOutput(w)
+	return e
+}
+
+// SetOutput changes where events are written. Pass nil to disable events.
+func (e *Emitter) SetOutput(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if w == nil {
+		e.enc = nil
+		return
+	}
+	e.enc = json.NewEncoder(w)
+}
+
+// Emit writes the event as one JSON line, stamping the time if unset.
+func (e *Emitter) Emit(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.enc == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = e.now().UTC()
+	}
+
+	// Encode only fails on unsupported values, which Event doesn't have.
+	_ = e.enc.Encode(ev)
+}
+
+// std is the process-wide emitter, disabled until SetOutput is called.
+var std = New(nil)
+
+// SetOutput sets the output of the process-wide emitter.
+func SetOutput(w io.Writer) { std.SetOutput(w) }
+
+// Emit writes an event to the process-wide emitter.
+func Emit(ev Event) { std.Emit(ev) }
+
+// Phase emits a phase transition event.
+func Phase(t Type, phase string) {
+	Emit(Event{Type: t, Phase: phase})
+}
+
+// Failure emits a phase failure event.
+func Failure(phase string, err error) {
+	Emit(Event{Type: PhaseFailed, Phase: phase, Status: StatusFailed, Error: err.Error()})
+}
+
+// Result emits a result event, marking it failed when err is not nil.
+func Result(t Type, pkg string, err error) {
+	ev := Event{Type: t, Package: pkg, Status: StatusOK}
+	if err != nil {
+		ev.Status = StatusFailed
+		ev.Error = err.Error()
+	}
+	Emit(ev)
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmitter_Emit(t *testing.T) {
+	t.Run("it writes one JSON object per line", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := New(&buf)
+		e.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+		e.Emit(Event{Type: PhaseFinished, Phase: "dotfiles"})
+		e.Emit(Event{Type: PackageResult, Package: "git", Status: StatusOK})
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+		}
+
+		var ev Event
+		if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+			t.Fatalf("line is not valid JSON: %v", err)
+		}
+		if ev.Type != PackageResult || ev.Package != "git" || ev.Status != StatusOK {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+		if !strings.Contains(lines[0], `"time":"2025-01-02T03:04:05Z"`) {
+			t.Errorf("expected the event to be timestamped, got %s", lines[0])
+		}
+		if strings.Contains(lines[0], `"package"`) {
+			t.Errorf("expected empty fields to be omitted, got %s", lines[0])
+		}
+	})
+
+	t.Run("it discards events without an output", func(t *testing.T) {
+		e := New(nil)
+
+		// Must not panic.
+		e.Emit(Event{Type: StowResult})
+	})
+}
+
+func TestResult(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(nil)
+
+	Result(PackageResult, "blender", errors.New("exit status 1"))
+
+	var ev Event
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if ev.Status != StatusFailed || ev.Error != "exit status 1" || ev.Package != "blender" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
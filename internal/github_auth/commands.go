@@ -143,6 +143,17 @@ func (s *Service) VerifyConnectionCmd() tea.Cmd {
 	}
 }
 
+// CheckAuth makes sure github.com is a known host and reports whether the
+// local SSH key is accepted by GitHub, without any user interaction.
+func (s *Service) CheckAuth() (isAuthenticated bool, username string, err error) {
+	if err := s.ensureGitHubKnownHost(); err != nil {
+		return false, "", err
+	}
+
+	isAuthenticated, username, _ = s.auth.CheckConnection()
+	return isAuthenticated, username, nil
+}
+
 func (s *Service) ensureGitHubKnownHost() error {
 	sshDir, err := s.getSshPath()
 	if err != nil {
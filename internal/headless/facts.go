@@ -0,0 +1,110 @@
+package headless
+
+import (
+	"archsetup/internal/profiles"
+	"archsetup/internal/system"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Facts prints the detected machine facts, as text or as a single JSON
+// object with --output=json.
+func (r *Runner) Facts(args []string) error {
+	var output string
+
+	fs := flag.NewFlagSet("facts", flag.ContinueOnError)
+	fs.SetOutput(r.errOut)
+	fs.StringVar(&output, "output", "text", "output format: text or json")
+
+	if err := fs.Parse(args); err != nil {
+		return &ExitError{Code: ExitUsage, Err: err}
+	}
+	if fs.NArg() > 0 {
+		return exitErrorf(ExitUsage, "unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	facts := r.profiles.Facts()
+
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(facts, "", "  ")
+		if err != nil {
+			return exitErrorf(ExitFailure, "could not encode facts: %w", err)
+		}
+		r.printf("%s\n", data)
+	case "text":
+		r.printf("%s", formatFacts(facts))
+	default:
+		return exitErrorf(ExitUsage, "--output must be text or json, got %q", output)
+	}
+
+	return nil
+}
+
+func formatFacts(f system.Facts) string {
+	var b strings.Builder
+	line := func(label, value string) {
+		fmt.Fprintf(&b, "%-17s%s\n", label+":", cmp.Or(value, "unknown"))
+	}
+
+	os := cmp.Or(f.OS.PrettyName, f.OS.Family)
+	if f.OS.Distro != "" {
+		details := strings.TrimSpace(f.OS.Distro + " " + f.OS.Version)
+		if len(f.OS.Like) > 0 {
+			details += ", like " + strings.Join(f.OS.Like, " ")
+		}
+		os += " (" + details + ")"
+	}
+	line("OS", os)
+
+	cpu := f.CPUVendor
+	if f.CPUModel != "" {
+		cpu += ", " + f.CPUModel
+	}
+	line("CPU", cpu)
+
+	var gpus []string
+	for _, gpu := range f.GPUs {
+		gpus = append(gpus, fmt.Sprintf("%s %s (%s)", gpu.Vendor, gpu.PCIID, gpu.Slot))
+	}
+	if len(gpus) == 0 {
+		gpus = f.GPUVendors
+	}
+	line("GPUs", strings.Join(gpus, ", "))
+
+	line("Chassis", f.Chassis)
+	line("Virtualization", cmp.Or(f.Virtualization, "none"))
+
+	kernel := f.Kernel
+	if len(f.Kernels) > 0 {
+		kernel += " (installed: " + strings.Join(f.Kernels, ", ") + ")"
+	}
+	line("Kernel", kernel)
+	line("Init", f.Init)
+	line("Boot mode", f.BootMode)
+
+	var memory string
+	if f.MemoryBytes > 0 {
+		memory = profiles.FormatSize(int64(f.MemoryBytes))
+	}
+	line("Memory", memory)
+
+	var disks []string
+	for _, d := range f.Disks {
+		kind := "SSD"
+		if d.Rotational {
+			kind = "HDD"
+		}
+		disk := fmt.Sprintf("%s %s %s", d.Name, profiles.FormatSize(int64(d.SizeBytes)), kind)
+		if d.Model != "" {
+			disk += " (" + d.Model + ")"
+		}
+		disks = append(disks, disk)
+	}
+	line("Disks", strings.Join(disks, ", "))
+
+	return b.String()
+}
@@ -0,0 +1,510 @@
+package headless
+
+import (
+	"archsetup/internal/dotfiles"
+	"archsetup/internal/events"
+	"archsetup/internal/github_auth"
+	"archsetup/internal/profiles"
+	"archsetup/internal/system"
+	"archsetup/internal/types"
+	"archsetup/internal/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Exit codes returned by the headless subcommands.
+const (
+	ExitOK       = 0
+	ExitFailure  = 1
+	ExitUsage    = 2
+	ExitAuth     = 3
+	ExitConfig   = 4
+	ExitPackages = 5
+)
+
+// ExitError carries the process exit code for a failed subcommand.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode maps an error returned by Run to a process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return ExitFailure
+}
+
+func exitErrorf(code int, format string, args ...any) error {
+	return &ExitError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Options are the flags shared by the plan and apply subcommands.
+type Options struct {
+	Repo        string
+	Dest        string
+	Profile     string
+	PostInstall bool
+	Output      string
+}
+
+// Runner drives the dotfiles, profiles and GitHub services without the TUI.
+type Runner struct {
+	exec        system.Executor
+	auth        *github_auth.Service
+	dotfiles    *dotfiles.Service
+	profiles    *profiles.Service
+	defaultDest string
+	out         io.Writer
+	errOut      io.Writer
+}
+
+func New(
+	exec system.Executor,
+	auth *github_auth.Service,
+	dotfilesSvc *dotfiles.Service,
+	profilesSvc *profiles.Service,
+	defaultDest string,
+	out io.Writer,
+	errOut io.Writer,
+) *Runner {
+	return &Runner{
+		exec:        exec,
+		auth:        auth,
+		dotfiles:    dotfilesSvc,
+		profiles:    profilesSvc,
+		defaultDest: defaultDest,
+		out:         out,
+		errOut:      errOut,
+	}
+}
+
+// IsSubcommand reports whether name is a headless subcommand.
+func IsSubcommand(name string) bool {
+	switch name {
+	case "plan", "apply", "facts":
+		return true
+	}
+
+	return false
+}
+
+// Run executes the subcommand in args[0] with the flags that follow it.
+func (r *Runner) Run(args []string) error {
+	if len(args) == 0 || !IsSubcommand(args[0]) {
+		return exitErrorf(ExitUsage, "expected a subcommand: plan, apply or facts")
+	}
+	if args[0] == "facts" {
+		return r.Facts(args[1:])
+	}
+
+	opts, err := r.parseOptions(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+
+	log.Printf("headless: running %s with %+v", args[0], opts)
+
+	// In JSON mode stdout carries only the event stream, so progress text
+	// and the output of child processes move to stderr.
+	if opts.Output == "json" {
+		out := r.out
+		events.SetOutput(out)
+		r.out = r.errOut
+		defer func() {
+			events.SetOutput(nil)
+			r.out = out
+		}()
+	}
+
+	switch args[0] {
+	case "plan":
+		return r.Plan(opts)
+	default:
+		return r.Apply(opts)
+	}
+}
+
+func (r *Runner) parseOptions(name string, args []string) (Options, error) {
+	var opts Options
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(r.errOut)
+	fs.StringVar(&opts.Repo, "repo", "", "GitHub dotfiles repository (username/repo)")
+	fs.StringVar(&opts.Dest, "dest", r.defaultDest, "where the dotfiles are (or will be) cloned")
+	fs.StringVar(&opts.Profile, "profile", "", "name of the profile in bas_settings.toml")
+	fs.BoolVar(&opts.PostInstall, "post-install", false, "run the profile's post_install command")
+	fs.StringVar(&opts.Output, "output", "text", "output format: text or json")
+
+	if err := fs.Parse(args); err != nil {
+		return Options{}, &ExitError{Code: ExitUsage, Err: err}
+	}
+	if fs.NArg() > 0 {
+		return Options{}, exitErrorf(ExitUsage, "unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if opts.Profile == "" {
+		return Options{}, exitErrorf(ExitUsage, "--profile is required")
+	}
+	if opts.Output != "text" && opts.Output != "json" {
+		return Options{}, exitErrorf(ExitUsage, "--output must be text or json, got %q", opts.Output)
+	}
+
+	dest, err := utils.ExpandHome(opts.Dest)
+	if err != nil {
+		return Options{}, &ExitError{Code: ExitUsage, Err: err}
+	}
+	opts.Dest = dest
+
+	return opts, nil
+}
+
+// Plan prints what Apply would do, without changing the machine. A dotfiles
+// repo that is not cloned yet is cloned into a temporary directory.
+func (r *Runner) Plan(opts Options) error {
+	dir := opts.Dest
+	if hasDotfiles(opts.Dest) {
+		r.printf("Dotfiles:        %s (existing)\n", opts.Dest)
+	} else {
+		tmp, err := os.MkdirTemp("", "bas-plan-*")
+		if err != nil {
+			return exitErrorf(ExitFailure, "could not create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		dir = filepath.Join(tmp, "dotfiles")
+		if err := r.cloneDotfiles(opts.Repo, dir); err != nil {
+			return err
+		}
+		r.printf("Dotfiles:        clone %s into %s\n", opts.Repo, opts.Dest)
+	}
+
+	profile, err := r.selectProfile(dir, opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	packages, err := r.profiles.LoadProfilePackages(dir, profile)
+	if err != nil {
+		return exitErrorf(ExitConfig, "%w", err)
+	}
+
+	r.printf("Profile:         %s\n", profile.Name)
+	r.printf("Stow:            %s\n", joinOrNone(profile.StowDirs))
+
+	pm, err := r.profiles.PackageManager()
+	switch {
+	case err != nil:
+		r.printf("Package manager: unavailable (%v)\n", err)
+	case pm.Detect():
+		r.printf("Package manager: %s (installed)\n", pm.Name())
+	default:
+		r.printf("Package manager: %s (will be installed)\n", pm.Name())
+	}
+
+	sets := r.classifyPackages(packages)
+	r.printf("Packages (%d):\n", len(packages))
+	for _, pkg := range packages {
+		if source := sets.Source(pkg); source != "" {
+			r.printf("  - %s (%s)\n", pkg, source)
+		} else {
+			r.printf("  - %s\n", pkg)
+		}
+	}
+
+	plan := r.profiles.BuildPlan(dir, profile.StowDirs, packages, sets)
+	r.printf("New:             %d (%d already installed)\n", len(plan.New), len(plan.Installed))
+	r.printf("Download size:   %s\n", profiles.FormatSize(plan.DownloadSize))
+	r.printf("Installed size:  %s\n", profiles.FormatSize(plan.InstalledSize))
+	for _, stow := range plan.Stow {
+		if stow.Err != "" {
+			r.printf("Stow %s: would fail: %s\n", stow.Dir, stow.Err)
+			continue
+		}
+		r.printf("Stow %s: %d links\n", stow.Dir, len(stow.Links))
+	}
+	for _, w := range plan.Warnings {
+		r.printf("Warning:         %s\n", w)
+	}
+
+	if defaults := profile.MacDefaults(system.CurrentOSInfo()); len(defaults) > 0 {
+		changes := r.profiles.ReadDefaults(defaults)
+		r.printf("macOS defaults (%d):\n", len(changes))
+		for line := range strings.Lines(profiles.FormatDefaults(changes)) {
+			r.printf("  %s", line)
+		}
+	}
+
+	switch {
+	case profile.PostInstall == nil:
+		r.printf("Post-install:    none\n")
+	case opts.PostInstall:
+		r.printf("Post-install:    %s\n", profile.PostInstall.Command)
+	default:
+		r.printf("Post-install:    %s (skipped, pass --post-install to run)\n", profile.PostInstall.Command)
+	}
+
+	return nil
+}
+
+// Apply clones the dotfiles if needed, stows them, installs the profile's
+// packages, applies its macOS defaults and optionally runs the post-install
+// command.
+func (r *Runner) Apply(opts Options) error {
+	err := r.dotfiles.CheckDestIsValid(opts.Dest)
+	switch {
+	case errors.Is(err, dotfiles.ErrDestDirExists):
+		r.printf("==> Using existing dotfiles at %s\n", opts.Dest)
+	case err != nil:
+		return exitErrorf(ExitFailure, "%w", err)
+	default:
+		r.printf("==> Cloning %s into %s\n", opts.Repo, opts.Dest)
+		if err := r.cloneDotfiles(opts.Repo, opts.Dest); err != nil {
+			return err
+		}
+	}
+	events.Phase(events.PhaseFinished, types.DotfilesPhase.String())
+
+	profile, err := r.selectProfile(opts.Dest, opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	packages, err := r.profiles.LoadProfilePackages(opts.Dest, profile)
+	if err != nil {
+		return exitErrorf(ExitConfig, "%w", err)
+	}
+
+	setup, err := r.profiles.PackageManagerSetupCommand()
+	if err != nil {
+		return exitErrorf(ExitFailure, "%w", err)
+	}
+	if setup != nil {
+		r.printf("==> Setting up package manager\n")
+		if err := r.runAttached(setup); err != nil {
+			return exitErrorf(ExitFailure, "failed to set up package manager: %w", err)
+		}
+	}
+
+	r.printf("==> Stowing %s\n", joinOrNone(profile.StowDirs))
+	err = r.profiles.Stow(opts.Dest, profile.StowDirs)
+	events.Result(events.StowResult, "", err)
+	if err != nil {
+		return exitErrorf(ExitFailure, "%w", err)
+	}
+
+	sets := r.classifyPackages(packages)
+	if len(sets.Unknown) > 0 {
+		r.printf("==> Not found in the repos or the AUR: %s\n", strings.Join(sets.Unknown, ", "))
+	}
+
+	failed := r.installPackages(packages, sets)
+
+	if defaults := profile.MacDefaults(system.CurrentOSInfo()); len(defaults) > 0 {
+		changes := r.profiles.ReadDefaults(defaults)
+		r.printf("==> Applying macOS defaults\n")
+		for line := range strings.Lines(profiles.FormatDefaults(changes)) {
+			r.printf("  %s", line)
+		}
+		err := r.profiles.ApplyDefaults(changes)
+		events.Result(events.DefaultsResult, "", err)
+		if err != nil {
+			return exitErrorf(ExitFailure, "%w", err)
+		}
+	}
+
+	if opts.PostInstall && profile.PostInstall != nil {
+		facts := r.profiles.Facts()
+		postInstall, err := profile.PostInstall.Render(profile.Machine(facts))
+		if err != nil {
+			return exitErrorf(ExitConfig, "%w", err)
+		}
+
+		r.printf("==> Running post-install: %s\n", postInstall.Command)
+		cmd := r.profiles.BuildPostInstallCmd(
+			opts.Dest,
+			postInstall,
+			profile.PostInstallEnv(facts),
+		)
+		err = r.runAttached(cmd)
+		events.Result(events.PostInstallResult, "", err)
+		if err != nil {
+			return exitErrorf(ExitFailure, "post-install failed: %w", err)
+		}
+	}
+	events.Phase(events.PhaseFinished, types.ProfilesPhase.String())
+
+	r.printf("Succeeded: %d, Failed: %d\n", len(packages)-len(failed), len(failed))
+	if tools := profiles.CountTools(packages); tools > 0 {
+		failedTools := profiles.CountTools(failed)
+		r.printf("Tools: %d succeeded, %d failed\n", tools-failedTools, failedTools)
+	}
+	if len(failed) > 0 {
+		return exitErrorf(ExitPackages, "failed to install packages: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// classifyPackages splits packages by source. Classification is best effort:
+// on failure every package is installed one by one.
+func (r *Runner) classifyPackages(packages []string) profiles.PackageSets {
+	sets, err := r.profiles.ClassifyPackages(packages)
+	if err != nil {
+		log.Printf("headless: could not classify packages: %v", err)
+	}
+
+	return sets
+}
+
+// installPackages installs packages in batches where the package manager
+// supports it. Packages of a failed batch are retried one by one so that
+// every failure is attributed to the right package, and tools that are
+// already installed are skipped. It returns the packages
+// that could not be installed.
+func (r *Runner) installPackages(
+	packages []string,
+	sets profiles.PackageSets,
+) []string {
+	installed := r.profiles.InstalledTools(packages)
+	packages = slices.DeleteFunc(slices.Clone(packages), func(pkg string) bool {
+		if installed[pkg] {
+			r.printf("==> Skipping %s, already installed\n", pkg)
+			events.Result(events.PackageResult, pkg, nil)
+		}
+		return installed[pkg]
+	})
+
+	batches := sets.Batches(packages)
+	queue := profiles.UnbatchedPackages(packages, batches)
+
+	for _, batch := range batches {
+		r.printf("==> Installing %d %s packages\n", len(batch.Packages), batch.Source)
+
+		cmd, err := r.profiles.BatchInstallCommand(batch)
+		if err == nil {
+			err = r.runAttached(cmd)
+		}
+		if err != nil {
+			log.Printf("headless: %s batch failed, retrying one by one: %v", batch.Source, err)
+			queue = append(queue, batch.Packages...)
+			continue
+		}
+		for _, pkg := range batch.Packages {
+			events.Result(events.PackageResult, pkg, nil)
+		}
+	}
+
+	var failed []string
+	for i, pkg := range queue {
+		r.printf("==> Installing (%d/%d): %s\n", i+1, len(queue), pkg)
+
+		cmd, err := r.profiles.PackageInstallCommand(pkg)
+		if err == nil {
+			err = r.runAttached(cmd)
+		}
+		events.Result(events.PackageResult, pkg, err)
+		if err != nil {
+			log.Printf("headless: failed to install package %s: %v", pkg, err)
+			failed = append(failed, pkg)
+		}
+	}
+
+	return failed
+}
+
+func (r *Runner) cloneDotfiles(repo, dest string) error {
+	if repo == "" {
+		return exitErrorf(ExitUsage, "--repo is required when %s does not contain the dotfiles", dest)
+	}
+
+	isAuthenticated, _, err := r.auth.CheckAuth()
+	if err != nil {
+		return exitErrorf(ExitAuth, "could not check GitHub authentication: %w", err)
+	}
+	if !isAuthenticated {
+		return exitErrorf(ExitAuth, "GitHub SSH authentication failed; run `bas-tui` to set up a key")
+	}
+
+	if err := r.dotfiles.CheckRepoExists(repo); err != nil {
+		return exitErrorf(ExitFailure, "%w", err)
+	}
+
+	if err := r.dotfiles.CloneRepo(repo, dest); err != nil {
+		return exitErrorf(ExitFailure, "%w", err)
+	}
+
+	return nil
+}
+
+func (r *Runner) selectProfile(dotfilesPath, name string) (profiles.Profile, error) {
+	cfg, err := r.profiles.LoadConfig(dotfilesPath)
+	if err != nil {
+		return profiles.Profile{}, exitErrorf(ExitConfig, "%w", err)
+	}
+
+	available := cfg.ProfilesFor(system.CurrentOSInfo(), r.profiles.Facts())
+	names := make([]string, 0, len(available))
+	for _, p := range available {
+		if strings.EqualFold(p.Name, name) {
+			backends, err := r.profiles.BackendConfig(dotfilesPath, p)
+			if err == nil {
+				err = r.profiles.UsePackageManager(p.PackageManager, backends)
+			}
+			if err != nil {
+				return profiles.Profile{}, exitErrorf(ExitConfig, "%w", err)
+			}
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+
+	return profiles.Profile{}, exitErrorf(
+		ExitConfig,
+		"profile %q not found for this OS (available: %s)",
+		name,
+		joinOrNone(names),
+	)
+}
+
+func (r *Runner) runAttached(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = r.out
+	cmd.Stderr = r.errOut
+
+	return r.exec.Run(cmd)
+}
+
+func (r *Runner) printf(format string, args ...any) {
+	fmt.Fprintf(r.out, format, args...)
+}
+
+func hasDotfiles(dest string) bool {
+	entries, err := os.ReadDir(dest)
+	return err == nil && len(entries) > 0
+}
+
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "(none)"
+	}
+
+	return strings.Join(items, ", ")
+}
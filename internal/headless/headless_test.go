@@ -0,0 +1,215 @@
+package headless
+
+import (
+	"archsetup/internal/dotfiles"
+	"archsetup/internal/profiles"
+	"archsetup/internal/system"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- Mocks ---
+
+type mockExecutor struct {
+	ran []*exec.Cmd
+}
+
+func (m *mockExecutor) Run(cmd *exec.Cmd) error {
+	m.ran = append(m.ran, cmd)
+	return nil
+}
+func (m *mockExecutor) RunPiped(cmd1 *exec.Cmd, cmd2 *exec.Cmd) error { return nil }
+func (m *mockExecutor) Output(cmd *exec.Cmd) ([]byte, error)          { return nil, nil }
+func (m *mockExecutor) CombinedOutput(cmd *exec.Cmd) ([]byte, error)  { return nil, nil }
+func (m *mockExecutor) IsRoot() bool                                  { return false }
+func (m *mockExecutor) CanSudo() bool                                 { return false }
+
+// --- Test Helpers ---
+
+func setupRunner(t *testing.T, dest string) (*Runner, *bytes.Buffer) {
+	t.Helper()
+
+	mockExec := &mockExecutor{}
+	fs := &system.LiveFileSystem{}
+	var out bytes.Buffer
+
+	runner := New(
+		mockExec,
+		nil,
+		dotfiles.NewService(mockExec, fs),
+		profiles.NewService(mockExec, fs),
+		dest,
+		&out,
+		&out,
+	)
+	return runner, &out
+}
+
+func writeDotfiles(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	settings := `[[profiles]]
+name = "Test Box"
+description = "A test profile"
+path = "packages.txt"
+stow_dirs = ["zsh", "git"]
+`
+	files := map[string]string{
+		"bas_settings.toml": settings,
+		"packages.txt":      "git\n# comment\nzsh\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	return dir
+}
+
+// --- Tests ---
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil error", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitFailure},
+		{"exit error", &ExitError{Code: ExitConfig, Err: errors.New("x")}, ExitConfig},
+		{"wrapped exit error", wrapErr(&ExitError{Code: ExitPackages, Err: errors.New("x")}), ExitPackages},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func wrapErr(err error) error {
+	return errors.Join(errors.New("application error"), err)
+}
+
+func TestRunner_Run_Usage(t *testing.T) {
+	t.Run("it requires a profile", func(t *testing.T) {
+		runner, _ := setupRunner(t, t.TempDir())
+
+		err := runner.Run([]string{"plan"})
+
+		if ExitCode(err) != ExitUsage {
+			t.Errorf("expected usage exit code, got %d (%v)", ExitCode(err), err)
+		}
+	})
+
+	t.Run("it rejects unknown subcommands", func(t *testing.T) {
+		runner, _ := setupRunner(t, t.TempDir())
+
+		err := runner.Run([]string{"destroy"})
+
+		if ExitCode(err) != ExitUsage {
+			t.Errorf("expected usage exit code, got %d (%v)", ExitCode(err), err)
+		}
+	})
+
+	t.Run("it requires a repo when the dotfiles are not cloned", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "dotfiles")
+		runner, _ := setupRunner(t, dest)
+
+		err := runner.Run([]string{"plan", "--profile", "Test Box"})
+
+		if ExitCode(err) != ExitUsage {
+			t.Errorf("expected usage exit code, got %d (%v)", ExitCode(err), err)
+		}
+	})
+}
+
+func TestRunner_Plan(t *testing.T) {
+	t.Run("it prints the plan for an existing clone", func(t *testing.T) {
+		dest := writeDotfiles(t)
+		runner, out := setupRunner(t, dest)
+
+		err := runner.Run([]string{"plan", "--profile", "test box"})
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, want := range []string{"Test Box", "zsh, git", "Packages (2)", "  - git", "(existing)"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected plan output to contain %q, got:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("it fails with a config exit code for unknown profiles", func(t *testing.T) {
+		dest := writeDotfiles(t)
+		runner, _ := setupRunner(t, dest)
+
+		err := runner.Run([]string{"plan", "--profile", "Nope"})
+
+		if ExitCode(err) != ExitConfig {
+			t.Errorf("expected config exit code, got %d (%v)", ExitCode(err), err)
+		}
+	})
+}
+
+func TestRunner_Apply_UnknownProfile(t *testing.T) {
+	dest := writeDotfiles(t)
+	runner, _ := setupRunner(t, dest)
+
+	err := runner.Run([]string{"apply", "--profile", "Nope"})
+
+	if ExitCode(err) != ExitConfig {
+		t.Errorf("expected config exit code, got %d (%v)", ExitCode(err), err)
+	}
+}
+
+func TestRunner_Facts(t *testing.T) {
+	t.Run("it prints the facts as text", func(t *testing.T) {
+		runner, out := setupRunner(t, t.TempDir())
+
+		if err := runner.Run([]string{"facts"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		for _, label := range []string{"OS:", "CPU:", "GPUs:", "Virtualization:", "Boot mode:", "Memory:"} {
+			if !strings.Contains(out.String(), label) {
+				t.Errorf("expected %q in output, got:\n%s", label, out.String())
+			}
+		}
+	})
+
+	t.Run("it prints the facts as JSON", func(t *testing.T) {
+		runner, out := setupRunner(t, t.TempDir())
+
+		if err := runner.Run([]string{"facts", "--output=json"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var facts system.Facts
+		if err := json.Unmarshal(out.Bytes(), &facts); err != nil {
+			t.Errorf("expected a JSON object, got %v:\n%s", err, out.String())
+		}
+	})
+
+	t.Run("it rejects unknown output formats", func(t *testing.T) {
+		runner, _ := setupRunner(t, t.TempDir())
+
+		err := runner.Run([]string{"facts", "--output=yaml"})
+
+		if ExitCode(err) != ExitUsage {
+			t.Errorf("expected usage exit code, got %d (%v)", ExitCode(err), err)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package nvidia
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/assert"
 	"archsetup/internal/navigator"
 	"archsetup/internal/styles"
@@ -31,6 +32,10 @@ type Model struct {
 	height    int
 	service   *Service
 	err       error
+
+	// answers is set for unattended runs; every confirmation is taken
+	// automatically.
+	answers *answers.Answers
 }
 
 func New(keys types.KeyMap, service *Service) *Model {
@@ -50,6 +55,12 @@ func (m *Model) Init() tea.Cmd {
 	m.nav.Reset(confirmationPhase)
 	m.selection = true
 	m.err = nil
+
+	if m.answers != nil {
+		m.selection = m.answers.InstallNvidia
+		return m.confirm()
+	}
+
 	return nil
 }
 
@@ -65,6 +76,14 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case InstallResultMsg:
 		return m.handleInstallResultMsg(msg)
 
+	case answers.LoadedMsg:
+		m.answers = &msg.Answers
+		return m, nil
+
+	case answers.ClearedMsg:
+		m.answers = nil
+		return m, nil
+
 	case tea.KeyMsg:
 		return m.handleKeyMsg(msg)
 
@@ -90,7 +109,15 @@ func (m *Model) handleInstallResultMsg(
 	} else {
 		m.nav.Push(successPhase)
 	}
-	return m, nil
+
+	if m.answers == nil {
+		return m, nil
+	}
+	if msg.Err != nil {
+		return m, func() tea.Msg { return types.PhaseFailed{Err: msg.Err} }
+	}
+
+	return m, func() tea.Msg { return types.PhaseFinished{} }
 }
 
 func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -113,17 +140,23 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case key.Matches(msg, m.keys.Enter):
-			if m.selection {
-				m.nav.Push(installingPhase)
-				return m, tea.Batch(m.spinner.Tick, m.service.InstallDriversCmd())
-			}
-			return m, func() tea.Msg { return types.PhaseCancelled{} }
+			return m, m.confirm()
 		}
 	}
 
 	return m.handleDefault(msg)
 }
 
+// confirm starts the driver installation if "Yes" is selected and cancels
+// the phase otherwise.
+func (m *Model) confirm() tea.Cmd {
+	if m.selection {
+		m.nav.Push(installingPhase)
+		return tea.Batch(m.spinner.Tick, m.service.InstallDriversCmd())
+	}
+	return func() tea.Msg { return types.PhaseCancelled{} }
+}
+
 func (m *Model) handleDefault(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	if m.nav.Current() == installingPhase {
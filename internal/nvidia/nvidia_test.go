@@ -1,6 +1,7 @@
 package nvidia
 
 import (
+	"archsetup/internal/answers"
 	"archsetup/internal/system"
 	"archsetup/internal/types"
 	"errors"
@@ -260,4 +261,53 @@ func TestModel_Update(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("Answers: Init declines the install when the answer file says no", func(t *testing.T) {
+		service := setupTestService(&mockExecutor{})
+		m := setupTestModel(service)
+		m.Update(answers.LoadedMsg{Answers: answers.Answers{InstallNvidia: false}})
+
+		cmd := m.Init()
+		if cmd == nil {
+			t.Fatal("expected a command but got nil")
+		}
+
+		if _, ok := cmd().(types.PhaseCancelled); !ok {
+			t.Error("expected the phase to be cancelled")
+		}
+	})
+
+	t.Run("Answers: InstallResultMsg finishes the phase", func(t *testing.T) {
+		service := setupTestService(&mockExecutor{})
+		m := setupTestModel(service)
+		m.Update(answers.LoadedMsg{Answers: answers.Answers{InstallNvidia: true}})
+		m.nav.Push(installingPhase)
+
+		_, cmd := m.Update(InstallResultMsg{Err: nil})
+		if cmd == nil {
+			t.Fatal("expected a command but got nil")
+		}
+
+		if _, ok := cmd().(types.PhaseFinished); !ok {
+			t.Error("expected the phase to finish")
+		}
+	})
+
+	t.Run("Answers: a failed install fails the phase", func(t *testing.T) {
+		service := setupTestService(&mockExecutor{})
+		m := setupTestModel(service)
+		m.Update(answers.LoadedMsg{Answers: answers.Answers{InstallNvidia: true}})
+		m.nav.Push(installingPhase)
+		installErr := errors.New("pacman failed")
+
+		_, cmd := m.Update(InstallResultMsg{Err: installErr})
+		if cmd == nil {
+			t.Fatal("expected a command but got nil")
+		}
+
+		failed, ok := cmd().(types.PhaseFailed)
+		if !ok || !errors.Is(failed.Err, installErr) {
+			t.Errorf("expected the phase to fail with the install error, got %+v", cmd())
+		}
+	})
 }
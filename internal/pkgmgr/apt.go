@@ -0,0 +1,223 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"archsetup/internal/utils"
+	"bufio"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AptConfig is a profile's [profiles.apt] table: the repositories to add
+// before installing.
+type AptConfig struct {
+	// PPAs are Launchpad archives such as "ppa:neovim-ppa/unstable".
+	PPAs  []string  `toml:"ppas"`
+	Repos []AptRepo `toml:"repos"`
+}
+
+// AptRepo is a third-party apt repository, e.g. Docker's. The key is saved
+// as /etc/apt/keyrings/<name>.asc, which the source line can refer to with
+// signed-by.
+type AptRepo struct {
+	Name   string `toml:"name"`
+	Key    string `toml:"key"`
+	Source string `toml:"source"`
+}
+
+// aptRepoName keeps repository names usable as file names.
+var aptRepoName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Validate reports the first malformed PPA or repository.
+func (c AptConfig) Validate() error {
+	for _, ppa := range c.PPAs {
+		if !strings.HasPrefix(ppa, "ppa:") {
+			return fmt.Errorf("apt: PPA %q must start with \"ppa:\"", ppa)
+		}
+	}
+	for _, repo := range c.Repos {
+		if !aptRepoName.MatchString(repo.Name) {
+			return fmt.Errorf("apt: invalid repository name %q", repo.Name)
+		}
+		if repo.Source == "" {
+			return fmt.Errorf("apt: repository %q needs a source", repo.Name)
+		}
+	}
+
+	return nil
+}
+
+// Merge applies child on top of c. Repositories of the same name are
+// replaced by the child's.
+func (c AptConfig) Merge(child AptConfig) AptConfig {
+	merged := AptConfig{PPAs: utils.MergeUnique(c.PPAs, child.PPAs)}
+
+	for _, repo := range slices.Concat(c.Repos, child.Repos) {
+		i := slices.IndexFunc(merged.Repos, func(r AptRepo) bool { return r.Name == repo.Name })
+		if i >= 0 {
+			merged.Repos[i] = repo
+		} else {
+			merged.Repos = append(merged.Repos, repo)
+		}
+	}
+
+	return merged
+}
+
+// apt installs packages on Debian, Ubuntu and their derivatives.
+type apt struct {
+	exec   system.Executor
+	config AptConfig
+}
+
+func (a *apt) Name() string { return Apt }
+
+func (a *apt) Supports(info system.OSInfo) bool {
+	return info.Family == "linux" && (info.IsLike("debian") || info.IsLike("ubuntu"))
+}
+
+func (a *apt) Detect() bool {
+	_, err := lookPath("apt-get")
+	return err == nil
+}
+
+func (a *apt) Bootstrap() *exec.Cmd { return nil }
+
+// Prepare adds the configured repositories and refreshes the package index,
+// which is empty on fresh installs and containers.
+func (a *apt) Prepare() *exec.Cmd {
+	var script strings.Builder
+	script.WriteString("set -e\nsudo apt-get update\n")
+
+	if len(a.config.PPAs) > 0 {
+		script.WriteString(aptInstall + " software-properties-common\n")
+		for _, ppa := range a.config.PPAs {
+			fmt.Fprintf(&script, "sudo add-apt-repository -y --no-update %s\n", shellQuote(ppa))
+		}
+	}
+
+	if len(a.config.Repos) > 0 {
+		script.WriteString(aptInstall + " ca-certificates curl\n")
+		script.WriteString("sudo install -m 0755 -d /etc/apt/keyrings\n")
+		for _, repo := range a.config.Repos {
+			if repo.Key != "" {
+				fmt.Fprintf(
+					&script,
+					"curl -fsSL %s | sudo tee /etc/apt/keyrings/%s.asc >/dev/null\n",
+					shellQuote(repo.Key),
+					repo.Name,
+				)
+			}
+			fmt.Fprintf(
+				&script,
+				"echo %s | sudo tee /etc/apt/sources.list.d/%s.list >/dev/null\n",
+				shellQuote(repo.Source),
+				repo.Name,
+			)
+		}
+	}
+
+	if len(a.config.PPAs) > 0 || len(a.config.Repos) > 0 {
+		script.WriteString("sudo apt-get update\n")
+	}
+
+	return exec.Command("bash", "-c", script.String())
+}
+
+// aptInstall installs without questions, including debconf prompts.
+const aptInstall = "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y"
+
+// IsInstalled reads the dpkg database. Packages that were removed but kept
+// their config files are not installed.
+func (a *apt) IsInstalled(packages []string) (map[string]bool, error) {
+	cmd := exec.Command("dpkg-query", "-W", "-f=${Package}\t${db:Status-Status}\n")
+	output, err := a.exec.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("could not list installed packages: %w", err)
+	}
+
+	all := make(map[string]bool)
+	for line := range strings.Lines(string(output)) {
+		name, status, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		if status == "installed" {
+			all[name] = true
+		}
+	}
+
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		if all[pkg] {
+			found[pkg] = true
+		}
+	}
+
+	return found, nil
+}
+
+func (a *apt) Install(packages []string) *exec.Cmd {
+	return exec.Command(
+		"sudo",
+		append([]string{"DEBIAN_FRONTEND=noninteractive", "apt-get", "install", "-y"}, packages...)...,
+	)
+}
+
+// Remove also takes the dependencies nothing needs anymore, without -y so
+// apt asks first.
+func (a *apt) Remove(packages []string) *exec.Cmd {
+	return exec.Command("sudo", append([]string{"apt-get", "remove", "--autoremove"}, packages...)...)
+}
+
+func (a *apt) Info(packages []string) (map[string]PackageInfo, error) {
+	if len(packages) == 0 {
+		return map[string]PackageInfo{}, nil
+	}
+
+	cmd := exec.Command("apt-cache", append([]string{"show", "--no-all-versions"}, packages...)...)
+	cmd.Env = append(cmd.Environ(), "LC_ALL=C")
+
+	// apt-cache exits non-zero when a name is unknown, but still prints the
+	// records of every other package.
+	output, err := a.exec.Output(cmd)
+	if err != nil {
+		log.Printf("pkgmgr: apt-cache show reported an error: %v", err)
+	}
+
+	return parseAptSizes(string(output)), nil
+}
+
+// parseAptSizes reads the download and installed sizes from the output of
+// `apt-cache show`. Size is in bytes, Installed-Size in KiB.
+func parseAptSizes(output string) map[string]PackageInfo {
+	sizes := make(map[string]PackageInfo)
+
+	var name string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		field, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		n, _ := strconv.ParseInt(value, 10, 64)
+
+		switch field {
+		case "Package":
+			name = value
+		case "Size":
+			info := sizes[name]
+			info.DownloadSize = n
+			sizes[name] = info
+		case "Installed-Size":
+			info := sizes[name]
+			info.InstalledSize = n << 10
+			sizes[name] = info
+		}
+	}
+
+	return sizes
+}
@@ -0,0 +1,99 @@
+package pkgmgr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApt_IsInstalled(t *testing.T) {
+	mockExec := &mockExecutor{output: []byte("git\tinstalled\nvim\tconfig-files\nzsh\tinstalled\n")}
+	pm, _ := New(Apt, mockExec, Config{})
+
+	got, err := pm.IsInstalled([]string{"git", "vim", "fzf"})
+
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]bool{"git": true}) {
+		t.Errorf("Expected only git, removed packages do not count, got %v", got)
+	}
+}
+
+func TestApt_Prepare(t *testing.T) {
+	t.Run("it only refreshes the index without repositories", func(t *testing.T) {
+		pm, _ := New(Apt, &mockExecutor{}, Config{})
+
+		script := pm.(Preparer).Prepare().Args[2]
+
+		if strings.Count(script, "apt-get update") != 1 || strings.Contains(script, "add-apt-repository") {
+			t.Errorf("Unexpected script %q", script)
+		}
+	})
+
+	t.Run("it adds PPAs and repositories before refreshing", func(t *testing.T) {
+		pm, _ := New(Apt, &mockExecutor{}, Config{Apt: AptConfig{
+			PPAs: []string{"ppa:neovim-ppa/unstable"},
+			Repos: []AptRepo{{
+				Name:   "docker",
+				Key:    "https://download.docker.com/linux/ubuntu/gpg",
+				Source: "deb [signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable",
+			}},
+		}})
+
+		script := pm.(Preparer).Prepare().Args[2]
+
+		for _, want := range []string{
+			"sudo add-apt-repository -y --no-update 'ppa:neovim-ppa/unstable'",
+			"curl -fsSL 'https://download.docker.com/linux/ubuntu/gpg' | sudo tee /etc/apt/keyrings/docker.asc",
+			"| sudo tee /etc/apt/sources.list.d/docker.list",
+		} {
+			if !strings.Contains(script, want) {
+				t.Errorf("Expected the script to contain %q, got %q", want, script)
+			}
+		}
+		if !strings.HasSuffix(script, "sudo apt-get update\n") {
+			t.Errorf("Expected the index to be refreshed last, got %q", script)
+		}
+	})
+}
+
+func TestAptConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AptConfig
+	}{
+		{"a PPA without prefix", AptConfig{PPAs: []string{"neovim-ppa/unstable"}}},
+		{"a repository name with a slash", AptConfig{Repos: []AptRepo{{Name: "../docker", Source: "deb x"}}}},
+		{"a repository without source", AptConfig{Repos: []AptRepo{{Name: "docker"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run("it rejects "+tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err == nil {
+				t.Error("Expected an error, but got nil")
+			}
+		})
+	}
+}
+
+func TestParseAptSizes(t *testing.T) {
+	output := `Package: git
+Version: 1:2.43.0-1ubuntu7
+Installed-Size: 22000
+Size: 3679104
+Description: fast, scalable, distributed revision control system
+
+Package: zsh
+Installed-Size: 2400
+Size: 810100
+`
+
+	want := map[string]PackageInfo{
+		"git": {DownloadSize: 3679104, InstalledSize: 22000 << 10},
+		"zsh": {DownloadSize: 810100, InstalledSize: 2400 << 10},
+	}
+	if got := parseAptSizes(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, but got %+v", want, got)
+	}
+}
@@ -0,0 +1,59 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"fmt"
+	"os/exec"
+)
+
+// aurHelper is an AUR helper such as yay or paru. It installs repo and AUR
+// packages alike and leaves everything else to pacman.
+type aurHelper struct {
+	pacman
+	name string
+}
+
+func newAURHelper(name string, exec system.Executor) *aurHelper {
+	return &aurHelper{pacman: pacman{exec: exec}, name: name}
+}
+
+func (h *aurHelper) Name() string { return h.name }
+
+func (h *aurHelper) Supports(info system.OSInfo) bool {
+	return info.IsArchLike()
+}
+
+func (h *aurHelper) Detect() bool {
+	_, err := lookPath(h.name)
+	return err == nil
+}
+
+// Bootstrap builds the helper from the AUR with makepkg.
+func (h *aurHelper) Bootstrap() *exec.Cmd {
+	return exec.Command("bash", "-c", fmt.Sprintf(aurHelperBootstrapScript, h.name))
+}
+
+func (h *aurHelper) Install(packages []string) *exec.Cmd {
+	return exec.Command(h.name, append([]string{"-S", "--needed", "--noconfirm"}, packages...)...)
+}
+
+const aurHelperBootstrapScript = `
+	set -e
+	echo "--- Installing dependencies for %[1]s (git, base-devel) ---"
+	sudo pacman -S --noconfirm --needed git base-devel
+
+	echo "--- Cloning %[1]s from AUR ---"
+	cd /tmp
+	if [ -d "%[1]s" ]; then rm -rf %[1]s; fi
+	git clone https://aur.archlinux.org/%[1]s.git
+
+	echo "--- Building and installing %[1]s ---"
+	cd %[1]s
+	makepkg -si --noconfirm
+
+	echo "--- Cleaning up ---"
+	cd /tmp
+	rm -rf %[1]s
+
+	echo "--- %[1]s installation complete! ---"
+`
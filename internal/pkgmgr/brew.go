@@ -0,0 +1,139 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"archsetup/internal/utils"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// BrewConfig is a profile's [profiles.brew] table.
+type BrewConfig struct {
+	// Taps are third-party repositories such as "homebrew/cask-fonts". A tap
+	// can name its git remote after a space: "me/tools https://...".
+	Taps []string `toml:"taps"`
+}
+
+// brewTap matches "user/repo", optionally followed by a remote.
+var brewTap = regexp.MustCompile(`^[A-Za-z0-9_-]+/[A-Za-z0-9_.-]+( \S+)?$`)
+
+// Validate reports the first malformed tap.
+func (c BrewConfig) Validate() error {
+	for _, tap := range c.Taps {
+		if !brewTap.MatchString(tap) {
+			return fmt.Errorf("brew: tap %q must look like user/repo", tap)
+		}
+	}
+
+	return nil
+}
+
+// Merge applies child on top of c, keeping c's taps first.
+func (c BrewConfig) Merge(child BrewConfig) BrewConfig {
+	return BrewConfig{Taps: utils.MergeUnique(c.Taps, child.Taps)}
+}
+
+// brew installs Homebrew formulae and casks, and Mac App Store apps through
+// mas, on macOS. Like `brew bundle`, it never guesses: plain names are
+// formulae, and casks and apps come with their own prefix.
+type brew struct {
+	exec   system.Executor
+	config BrewConfig
+}
+
+func (b *brew) Name() string { return Homebrew }
+
+func (b *brew) Supports(info system.OSInfo) bool {
+	return info.Family == "darwin"
+}
+
+func (b *brew) Detect() bool {
+	_, err := lookPath("brew")
+	return err == nil
+}
+
+func (b *brew) Bootstrap() *exec.Cmd {
+	return exec.Command("bash", "-c", brewBootstrapScript)
+}
+
+// Prepare taps the configured repositories, which are already tapped on
+// later runs.
+func (b *brew) Prepare() *exec.Cmd {
+	if len(b.config.Taps) == 0 {
+		return nil
+	}
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	for _, tap := range b.config.Taps {
+		fmt.Fprintf(&script, "brew tap %s\n", shellJoin(strings.Fields(tap)))
+	}
+
+	return exec.Command("bash", "-lc", script.String())
+}
+
+// IsInstalled looks the packages up among the installed formulae and casks,
+// and among the Mac App Store apps by id when mas is installed.
+func (b *brew) IsInstalled(packages []string) (map[string]bool, error) {
+	found, err := installed(b.exec, exec.Command("brew", "list", "-1", "--formula"), packages)
+	if err != nil {
+		return nil, err
+	}
+
+	casks, err := installed(b.exec, exec.Command("brew", "list", "-1", "--cask"), packages)
+	if err != nil {
+		return nil, err
+	}
+	for name := range casks {
+		found[name] = true
+	}
+
+	// mas prints "497799835  Xcode  (15.4)" per app.
+	output, err := b.exec.Output(exec.Command("mas", "list"))
+	if err != nil {
+		log.Printf("pkgmgr: could not list Mac App Store apps: %v", err)
+		return found, nil
+	}
+	apps := make(map[string]bool)
+	for line := range strings.Lines(string(output)) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			apps[fields[0]] = true
+		}
+	}
+	for _, pkg := range packages {
+		if apps[pkg] {
+			found[pkg] = true
+		}
+	}
+
+	return found, nil
+}
+
+// Install installs formulae; casks and apps are installed by their prefix.
+func (b *brew) Install(packages []string) *exec.Cmd {
+	return exec.Command("brew", append([]string{"install", "--formula"}, packages...)...)
+}
+
+func (b *brew) Remove(packages []string) *exec.Cmd {
+	return exec.Command("brew", append([]string{"uninstall"}, packages...)...)
+}
+
+// Info is empty: Homebrew has no cheap way to tell sizes before installing.
+func (b *brew) Info(packages []string) (map[string]PackageInfo, error) {
+	return map[string]PackageInfo{}, nil
+}
+
+const brewBootstrapScript = `
+	set -e
+	if ! command -v brew >/dev/null 2>&1; then
+	  echo '--- Installing Homebrew ---'
+	  /bin/bash -c "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"
+	  test -x /opt/homebrew/bin/brew && eval "$(/opt/homebrew/bin/brew shellenv)"
+	  test -x /usr/local/bin/brew   && eval "$(/usr/local/bin/brew shellenv)"
+	fi
+	echo '--- Ensuring prerequisites on macOS ---'
+	brew install ansible stow
+`
@@ -0,0 +1,87 @@
+package pkgmgr
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBrew_IsInstalled(t *testing.T) {
+	outputs := map[string]string{
+		"brew list -1 --formula": "git\nstow\n",
+		"brew list -1 --cask":    "firefox\n",
+		"mas list":               "497799835  Xcode  (15.4)\n",
+	}
+
+	t.Run("it finds formulae, casks and apps", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(outputs[strings.Join(cmd.Args, " ")]), nil
+		}}
+		pm, _ := New(Homebrew, mockExec, Config{})
+
+		got, err := pm.IsInstalled([]string{"git", "firefox", "497799835", "zsh"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := map[string]bool{"git": true, "firefox": true, "497799835": true}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, but got %v", want, got)
+		}
+	})
+
+	t.Run("it works without mas", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			if cmd.Args[0] == "mas" {
+				return nil, errors.New("not found")
+			}
+			return []byte(outputs[strings.Join(cmd.Args, " ")]), nil
+		}}
+		pm, _ := New(Homebrew, mockExec, Config{})
+
+		got, err := pm.IsInstalled([]string{"git"})
+
+		if err != nil || !got["git"] {
+			t.Errorf("Expected git to be installed, got %v (%v)", got, err)
+		}
+	})
+}
+
+func TestBrew_Prepare(t *testing.T) {
+	t.Run("it has nothing to do without taps", func(t *testing.T) {
+		pm, _ := New(Homebrew, &mockExecutor{}, Config{})
+
+		if cmd := pm.(Preparer).Prepare(); cmd != nil {
+			t.Errorf("Expected no command, but got %v", cmd.Args)
+		}
+	})
+
+	t.Run("it taps every repository", func(t *testing.T) {
+		pm, _ := New(Homebrew, &mockExecutor{}, Config{Brew: BrewConfig{Taps: []string{
+			"homebrew/cask-fonts",
+			"me/tools https://example.com/me/homebrew-tools.git",
+		}}})
+
+		script := pm.(Preparer).Prepare().Args[2]
+
+		for _, want := range []string{
+			"brew tap 'homebrew/cask-fonts'\n",
+			"brew tap 'me/tools' 'https://example.com/me/homebrew-tools.git'\n",
+		} {
+			if !strings.Contains(script, want) {
+				t.Errorf("Expected the script to contain %q, got %q", want, script)
+			}
+		}
+	})
+}
+
+func TestBrewConfig_Validate(t *testing.T) {
+	if err := (BrewConfig{Taps: []string{"cask-fonts"}}).Validate(); err == nil {
+		t.Error("Expected an error for a tap without user, but got nil")
+	}
+	if err := (BrewConfig{Taps: []string{"me/tools https://example.com/tools.git"}}).Validate(); err != nil {
+		t.Errorf("Expected a tap with a remote to be valid, but got %v", err)
+	}
+}
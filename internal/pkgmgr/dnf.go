@@ -0,0 +1,164 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"archsetup/internal/utils"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DnfConfig is a profile's [profiles.dnf] table: the repositories to enable
+// before installing.
+type DnfConfig struct {
+	// Copr are COPR projects such as "atim/starship".
+	Copr []string `toml:"copr"`
+	// RPMFusion lists the RPM Fusion repositories to enable: "free",
+	// "nonfree" or both.
+	RPMFusion []string `toml:"rpmfusion"`
+}
+
+// rpmFusionRepos are the RPM Fusion repositories a profile can enable.
+var rpmFusionRepos = []string{"free", "nonfree"}
+
+// coprProject matches "owner/project" and "@group/project".
+var coprProject = regexp.MustCompile(`^@?[A-Za-z0-9._-]+/[A-Za-z0-9._+-]+$`)
+
+// Validate reports the first malformed COPR project or RPM Fusion repository.
+func (c DnfConfig) Validate() error {
+	for _, project := range c.Copr {
+		if !coprProject.MatchString(project) {
+			return fmt.Errorf("dnf: COPR project %q must look like owner/project", project)
+		}
+	}
+	for _, repo := range c.RPMFusion {
+		if !slices.Contains(rpmFusionRepos, repo) {
+			return fmt.Errorf(
+				"dnf: unknown RPM Fusion repository %q (expected one of %s)",
+				repo,
+				strings.Join(rpmFusionRepos, ", "),
+			)
+		}
+	}
+
+	return nil
+}
+
+// Merge applies child on top of c, keeping c's entries first.
+func (c DnfConfig) Merge(child DnfConfig) DnfConfig {
+	return DnfConfig{
+		Copr:      utils.MergeUnique(c.Copr, child.Copr),
+		RPMFusion: utils.MergeUnique(c.RPMFusion, child.RPMFusion),
+	}
+}
+
+// dnf installs packages on Fedora and the RHEL family.
+type dnf struct {
+	exec   system.Executor
+	config DnfConfig
+}
+
+func (d *dnf) Name() string { return Dnf }
+
+func (d *dnf) Supports(info system.OSInfo) bool {
+	return info.Family == "linux" && (info.IsLike("fedora") || info.IsLike("rhel"))
+}
+
+func (d *dnf) Detect() bool {
+	_, err := lookPath("dnf")
+	return err == nil
+}
+
+func (d *dnf) Bootstrap() *exec.Cmd { return nil }
+
+// Prepare enables the configured RPM Fusion repositories and COPR projects.
+// dnf refreshes its metadata by itself, so there is nothing to do without
+// them.
+func (d *dnf) Prepare() *exec.Cmd {
+	if len(d.config.Copr) == 0 && len(d.config.RPMFusion) == 0 {
+		return nil
+	}
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+
+	if len(d.config.RPMFusion) > 0 {
+		// RPM Fusion publishes release packages per Fedora and per EL
+		// version; rpm leaves %fedora unexpanded on EL.
+		script.WriteString(`release=fedora version=$(rpm -E %fedora)
+if [ "$version" = "%fedora" ]; then release=el version=$(rpm -E %rhel); fi
+`)
+		for _, repo := range d.config.RPMFusion {
+			fmt.Fprintf(
+				&script,
+				"sudo dnf install -y https://mirrors.rpmfusion.org/%[1]s/$release/rpmfusion-%[1]s-release-$version.noarch.rpm\n",
+				repo,
+			)
+		}
+	}
+
+	if len(d.config.Copr) > 0 {
+		script.WriteString("sudo dnf install -y 'dnf-command(copr)'\n")
+		for _, project := range d.config.Copr {
+			fmt.Fprintf(&script, "sudo dnf copr enable -y %s\n", shellQuote(project))
+		}
+	}
+
+	return exec.Command("bash", "-c", script.String())
+}
+
+func (d *dnf) IsInstalled(packages []string) (map[string]bool, error) {
+	return installed(d.exec, exec.Command("rpm", "-qa", "--qf", `%{NAME}\n`), packages)
+}
+
+func (d *dnf) Install(packages []string) *exec.Cmd {
+	return exec.Command("sudo", append([]string{"dnf", "install", "-y"}, packages...)...)
+}
+
+// Remove also takes the dependencies nothing needs anymore, without -y so
+// dnf asks first.
+func (d *dnf) Remove(packages []string) *exec.Cmd {
+	return exec.Command("sudo", append([]string{"dnf", "remove"}, packages...)...)
+}
+
+func (d *dnf) Info(packages []string) (map[string]PackageInfo, error) {
+	if len(packages) == 0 {
+		return map[string]PackageInfo{}, nil
+	}
+
+	args := append([]string{
+		"repoquery", "--quiet", "--latest-limit=1",
+		"--qf", `%{name} %{downloadsize} %{installsize}\n`,
+	}, packages...)
+
+	output, err := d.exec.Output(exec.Command("dnf", args...))
+	if err != nil {
+		log.Printf("pkgmgr: dnf repoquery reported an error: %v", err)
+	}
+
+	return parseDnfSizes(string(output)), nil
+}
+
+// parseDnfSizes reads "name download-size installed-size" lines, in bytes.
+func parseDnfSizes(output string) map[string]PackageInfo {
+	sizes := make(map[string]PackageInfo)
+	for line := range strings.Lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		download, err1 := strconv.ParseInt(fields[1], 10, 64)
+		installed, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		sizes[fields[0]] = PackageInfo{DownloadSize: download, InstalledSize: installed}
+	}
+
+	return sizes
+}
@@ -0,0 +1,84 @@
+package pkgmgr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDnf_Prepare(t *testing.T) {
+	t.Run("it has nothing to do without repositories", func(t *testing.T) {
+		pm, _ := New(Dnf, &mockExecutor{}, Config{})
+
+		if cmd := pm.(Preparer).Prepare(); cmd != nil {
+			t.Errorf("Expected no command, but got %v", cmd.Args)
+		}
+	})
+
+	t.Run("it enables RPM Fusion and COPR projects", func(t *testing.T) {
+		pm, _ := New(Dnf, &mockExecutor{}, Config{Dnf: DnfConfig{
+			Copr:      []string{"atim/starship"},
+			RPMFusion: []string{"free", "nonfree"},
+		}})
+
+		script := pm.(Preparer).Prepare().Args[2]
+
+		for _, want := range []string{
+			"https://mirrors.rpmfusion.org/free/$release/rpmfusion-free-release-$version.noarch.rpm",
+			"https://mirrors.rpmfusion.org/nonfree/$release/rpmfusion-nonfree-release-$version.noarch.rpm",
+			"sudo dnf copr enable -y 'atim/starship'",
+		} {
+			if !strings.Contains(script, want) {
+				t.Errorf("Expected the script to contain %q, got %q", want, script)
+			}
+		}
+	})
+}
+
+func TestDnfConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DnfConfig
+		valid  bool
+	}{
+		{"a COPR project", DnfConfig{Copr: []string{"atim/starship"}}, true},
+		{"a group COPR project", DnfConfig{Copr: []string{"@cosmic/cosmic"}}, true},
+		{"a COPR project without owner", DnfConfig{Copr: []string{"starship"}}, false},
+		{"an unknown RPM Fusion repository", DnfConfig{RPMFusion: []string{"tainted"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run("it checks "+tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Expected valid to be %v, but got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestDnfConfig_Merge(t *testing.T) {
+	parent := DnfConfig{Copr: []string{"atim/starship"}, RPMFusion: []string{"free"}}
+	child := DnfConfig{Copr: []string{"atim/starship", "lazygit/lazygit"}, RPMFusion: []string{"nonfree"}}
+
+	got := parent.Merge(child)
+
+	want := DnfConfig{
+		Copr:      []string{"atim/starship", "lazygit/lazygit"},
+		RPMFusion: []string{"free", "nonfree"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, but got %+v", want, got)
+	}
+}
+
+func TestParseDnfSizes(t *testing.T) {
+	output := "git 54321 123456\n\nzsh 3000000 9000000\n"
+
+	want := map[string]PackageInfo{
+		"git": {DownloadSize: 54321, InstalledSize: 123456},
+		"zsh": {DownloadSize: 3000000, InstalledSize: 9000000},
+	}
+	if got := parseDnfSizes(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, but got %+v", want, got)
+	}
+}
@@ -0,0 +1,214 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Flatpak is the name of the Flatpak backend. It installs "flatpak:"
+// entries next to the system package manager and is never a default.
+const Flatpak = "flatpak"
+
+// Flatpak installation scopes.
+const (
+	FlatpakSystem = "system"
+	FlatpakUser   = "user"
+)
+
+// Flathub is the remote Flatpak apps come from unless the profile lists
+// others.
+var Flathub = FlatpakRemote{Name: "flathub", URL: "https://dl.flathub.org/repo/flathub.flatpakrepo"}
+
+// FlatpakConfig is a profile's [profiles.flatpak] table.
+type FlatpakConfig struct {
+	// Scope is "system" (the default) or "user".
+	Scope string `toml:"scope"`
+	// Remotes are added before installing. The first one is where entries
+	// without a remote come from.
+	Remotes []FlatpakRemote `toml:"remotes"`
+}
+
+// FlatpakRemote is a Flatpak repository, added from its .flatpakrepo file.
+type FlatpakRemote struct {
+	Name string `toml:"name"`
+	URL  string `toml:"url"`
+}
+
+// flatpakRemoteName matches the remote names flatpak accepts.
+var flatpakRemoteName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Validate reports an unknown scope or the first malformed remote.
+func (c FlatpakConfig) Validate() error {
+	if c.Scope != "" && c.Scope != FlatpakSystem && c.Scope != FlatpakUser {
+		return fmt.Errorf("flatpak: scope %q must be %q or %q", c.Scope, FlatpakSystem, FlatpakUser)
+	}
+	for _, remote := range c.Remotes {
+		if !flatpakRemoteName.MatchString(remote.Name) {
+			return fmt.Errorf("flatpak: invalid remote name %q", remote.Name)
+		}
+		if remote.URL == "" {
+			return fmt.Errorf("flatpak: remote %q needs a url", remote.Name)
+		}
+	}
+
+	return nil
+}
+
+// Merge applies child on top of c. The child's scope wins when it sets one,
+// and remotes of the same name are replaced by the child's.
+func (c FlatpakConfig) Merge(child FlatpakConfig) FlatpakConfig {
+	merged := FlatpakConfig{Scope: c.Scope}
+	if child.Scope != "" {
+		merged.Scope = child.Scope
+	}
+
+	for _, remote := range slices.Concat(c.Remotes, child.Remotes) {
+		i := slices.IndexFunc(merged.Remotes, func(r FlatpakRemote) bool { return r.Name == remote.Name })
+		if i >= 0 {
+			merged.Remotes[i] = remote
+		} else {
+			merged.Remotes = append(merged.Remotes, remote)
+		}
+	}
+
+	return merged
+}
+
+// remotes returns the configured remotes, or Flathub.
+func (c FlatpakConfig) remotes() []FlatpakRemote {
+	if len(c.Remotes) == 0 {
+		return []FlatpakRemote{Flathub}
+	}
+
+	return c.Remotes
+}
+
+// scopeFlag selects the installation flatpak works on.
+func (c FlatpakConfig) scopeFlag() string {
+	if c.Scope == FlatpakUser {
+		return "--user"
+	}
+
+	return "--system"
+}
+
+// flatpak installs apps from Flatpak remotes. Packages are app ids, or
+// "remote:app-id" to pick a remote other than the first.
+type flatpak struct {
+	exec   system.Executor
+	config FlatpakConfig
+}
+
+// NewFlatpak returns the Flatpak backend.
+func NewFlatpak(exec system.Executor, cfg Config) PackageManager {
+	return &flatpak{exec: exec, config: cfg.Flatpak}
+}
+
+func (f *flatpak) Name() string { return Flatpak }
+
+// Supports reports whether Flatpak works on the OS at all; it is never the
+// system package manager.
+func (f *flatpak) Supports(info system.OSInfo) bool {
+	return info.Family == "linux"
+}
+
+func (f *flatpak) Detect() bool {
+	_, err := lookPath("flatpak")
+	return err == nil
+}
+
+// Bootstrap is nil: flatpak comes from the system package manager, so it
+// belongs in the package list.
+func (f *flatpak) Bootstrap() *exec.Cmd { return nil }
+
+// Prepare adds the remotes that are missing in the profile's scope.
+func (f *flatpak) Prepare() *exec.Cmd {
+	return exec.Command("bash", "-c", strings.Join(f.addRemotes(), " && "))
+}
+
+// addRemotes returns the shell commands that add the remotes.
+func (f *flatpak) addRemotes() []string {
+	lines := make([]string, 0, len(f.config.remotes()))
+	for _, remote := range f.config.remotes() {
+		lines = append(lines, shellJoin([]string{
+			"flatpak", "remote-add", f.config.scopeFlag(), "--if-not-exists", remote.Name, remote.URL,
+		}))
+	}
+
+	return lines
+}
+
+// IsInstalled lists the apps of both scopes, so an app installed for the
+// user is not installed again system-wide.
+func (f *flatpak) IsInstalled(packages []string) (map[string]bool, error) {
+	cmd := exec.Command("flatpak", "list", "--app", "--columns=application")
+	output, err := f.exec.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("could not list installed flatpaks: %w", err)
+	}
+
+	all := make(map[string]bool)
+	for _, id := range strings.Fields(string(output)) {
+		all[id] = true
+	}
+
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		if _, id := f.splitRemote(pkg); all[id] {
+			found[pkg] = true
+		}
+	}
+
+	return found, nil
+}
+
+// Install adds the remotes first: flatpak itself is often installed by the
+// system package manager only moments before.
+func (f *flatpak) Install(packages []string) *exec.Cmd {
+	lines := f.addRemotes()
+
+	var remotes []string
+	byRemote := make(map[string][]string)
+	for _, pkg := range packages {
+		remote, id := f.splitRemote(pkg)
+		if _, ok := byRemote[remote]; !ok {
+			remotes = append(remotes, remote)
+		}
+		byRemote[remote] = append(byRemote[remote], id)
+	}
+	for _, remote := range remotes {
+		args := []string{"flatpak", "install", f.config.scopeFlag(), "-y", "--noninteractive", remote}
+		lines = append(lines, shellJoin(append(args, byRemote[remote]...)))
+	}
+
+	return exec.Command("bash", "-c", strings.Join(lines, " && "))
+}
+
+func (f *flatpak) Remove(packages []string) *exec.Cmd {
+	ids := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		_, id := f.splitRemote(pkg)
+		ids = append(ids, id)
+	}
+
+	return exec.Command("flatpak", append([]string{"uninstall", f.config.scopeFlag()}, ids...)...)
+}
+
+// Info is empty: flatpak only knows sizes once it resolves dependencies.
+func (f *flatpak) Info(packages []string) (map[string]PackageInfo, error) {
+	return map[string]PackageInfo{}, nil
+}
+
+// splitRemote splits "remote:app-id"; plain app ids come from the first
+// remote.
+func (f *flatpak) splitRemote(pkg string) (remote, id string) {
+	if remote, id, ok := strings.Cut(pkg, ":"); ok {
+		return remote, id
+	}
+
+	return f.config.remotes()[0].Name, pkg
+}
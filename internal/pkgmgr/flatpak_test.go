@@ -0,0 +1,98 @@
+package pkgmgr
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlatpak_Install(t *testing.T) {
+	t.Run("it adds flathub and installs system-wide by default", func(t *testing.T) {
+		pm := NewFlatpak(&mockExecutor{}, Config{})
+
+		cmd := pm.Install([]string{"com.spotify.Client", "com.discordapp.Discord"})
+
+		want := "'flatpak' 'remote-add' '--system' '--if-not-exists' 'flathub' 'https://dl.flathub.org/repo/flathub.flatpakrepo'" +
+			" && 'flatpak' 'install' '--system' '-y' '--noninteractive' 'flathub' 'com.spotify.Client' 'com.discordapp.Discord'"
+		if got := cmd.Args[2]; got != want {
+			t.Errorf("Expected script\n%s\nbut got\n%s", want, got)
+		}
+	})
+
+	t.Run("it installs for the user from the profile's remotes", func(t *testing.T) {
+		pm := NewFlatpak(&mockExecutor{}, Config{Flatpak: FlatpakConfig{
+			Scope: FlatpakUser,
+			Remotes: []FlatpakRemote{
+				{Name: "flathub", URL: "https://dl.flathub.org/repo/flathub.flatpakrepo"},
+				{Name: "gnome-nightly", URL: "https://nightly.gnome.org/gnome-nightly.flatpakrepo"},
+			},
+		}})
+
+		script := pm.Install([]string{"com.spotify.Client", "gnome-nightly:org.gnome.Builder"}).Args[2]
+
+		for _, want := range []string{
+			"'flatpak' 'remote-add' '--user' '--if-not-exists' 'gnome-nightly'",
+			"'flatpak' 'install' '--user' '-y' '--noninteractive' 'flathub' 'com.spotify.Client'",
+			"'flatpak' 'install' '--user' '-y' '--noninteractive' 'gnome-nightly' 'org.gnome.Builder'",
+		} {
+			if !strings.Contains(script, want) {
+				t.Errorf("Expected the script to contain %q, but got\n%s", want, script)
+			}
+		}
+	})
+}
+
+func TestFlatpak_IsInstalled(t *testing.T) {
+	t.Run("it finds installed apps by id", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte("com.spotify.Client\norg.gnome.Builder\n"), nil
+		}}
+		pm := NewFlatpak(mockExec, Config{})
+
+		got, err := pm.IsInstalled([]string{"com.spotify.Client", "gnome-nightly:org.gnome.Builder", "com.discordapp.Discord"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := map[string]bool{"com.spotify.Client": true, "gnome-nightly:org.gnome.Builder": true}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, but got %v", want, got)
+		}
+	})
+}
+
+func TestFlatpakConfig(t *testing.T) {
+	t.Run("it rejects an unknown scope", func(t *testing.T) {
+		if err := (FlatpakConfig{Scope: "global"}).Validate(); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("it needs a url for every remote", func(t *testing.T) {
+		if err := (FlatpakConfig{Remotes: []FlatpakRemote{{Name: "flathub"}}}).Validate(); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("it lets the child set the scope and replace remotes", func(t *testing.T) {
+		parent := FlatpakConfig{
+			Scope:   FlatpakSystem,
+			Remotes: []FlatpakRemote{{Name: "flathub", URL: "https://a"}},
+		}
+		child := FlatpakConfig{
+			Scope:   FlatpakUser,
+			Remotes: []FlatpakRemote{{Name: "flathub", URL: "https://b"}, {Name: "kde", URL: "https://c"}},
+		}
+
+		got := parent.Merge(child)
+
+		want := FlatpakConfig{
+			Scope:   FlatpakUser,
+			Remotes: []FlatpakRemote{{Name: "flathub", URL: "https://b"}, {Name: "kde", URL: "https://c"}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %+v, but got %+v", want, got)
+		}
+	})
+}
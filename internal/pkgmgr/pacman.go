@@ -0,0 +1,113 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"bufio"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pacman installs official repo packages on Arch and its derivatives.
+type pacman struct {
+	exec system.Executor
+}
+
+func (p *pacman) Name() string { return Pacman }
+
+// Supports is false: pacman is never the default, as it cannot install the
+// AUR packages most Arch package lists have. Profiles can still select it.
+func (p *pacman) Supports(info system.OSInfo) bool {
+	return false
+}
+
+func (p *pacman) Detect() bool {
+	_, err := lookPath("pacman")
+	return err == nil
+}
+
+func (p *pacman) Bootstrap() *exec.Cmd { return nil }
+
+func (p *pacman) IsInstalled(packages []string) (map[string]bool, error) {
+	return installed(p.exec, exec.Command("pacman", "-Qq"), packages)
+}
+
+func (p *pacman) Install(packages []string) *exec.Cmd {
+	return exec.Command("sudo", append([]string{"pacman", "-S", "--needed", "--noconfirm"}, packages...)...)
+}
+
+// Remove takes dependencies and config files along, without --noconfirm so
+// pacman asks before removing anything.
+func (p *pacman) Remove(packages []string) *exec.Cmd {
+	return exec.Command("sudo", append([]string{"pacman", "-Rns"}, packages...)...)
+}
+
+func (p *pacman) Info(packages []string) (map[string]PackageInfo, error) {
+	if len(packages) == 0 {
+		return map[string]PackageInfo{}, nil
+	}
+
+	cmd := exec.Command("pacman", append([]string{"-Si"}, packages...)...)
+	cmd.Env = append(cmd.Environ(), "LC_ALL=C")
+
+	// pacman exits non-zero when a name is a group, but still prints the
+	// information of every other package.
+	output, err := p.exec.Output(cmd)
+	if err != nil {
+		log.Printf("pkgmgr: pacman -Si reported an error: %v", err)
+	}
+
+	return parsePacmanSizes(string(output)), nil
+}
+
+// parsePacmanSizes reads the download and installed sizes from the output
+// of `pacman -Si`, keyed by package name.
+func parsePacmanSizes(output string) map[string]PackageInfo {
+	sizes := make(map[string]PackageInfo)
+
+	var name string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		field, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		field = strings.TrimSpace(field)
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "Name":
+			name = value
+		case "Download Size":
+			info := sizes[name]
+			info.DownloadSize = parseSize(value)
+			sizes[name] = info
+		case "Installed Size":
+			info := sizes[name]
+			info.InstalledSize = parseSize(value)
+			sizes[name] = info
+		}
+	}
+
+	return sizes
+}
+
+// parseSize converts sizes like "1.50 MiB" to bytes.
+func parseSize(s string) int64 {
+	number, unit, _ := strings.Cut(s, " ")
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+
+	multipliers := map[string]float64{
+		"B":   1,
+		"KiB": 1 << 10,
+		"MiB": 1 << 20,
+		"GiB": 1 << 30,
+		"TiB": 1 << 40,
+	}
+
+	return int64(value * multipliers[unit])
+}
@@ -0,0 +1,259 @@
+// Package pkgmgr provides the package manager backends BAS installs
+// packages with.
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Backend names.
+const (
+	Pacman   = "pacman"
+	Yay      = "yay"
+	Paru     = "paru"
+	Apt      = "apt"
+	Dnf      = "dnf"
+	Homebrew = "brew"
+)
+
+// ErrUnsupported is returned when no backend supports the current OS.
+var ErrUnsupported = errors.New("no package manager supports this system")
+
+// PackageInfo is what a backend knows about a package before installing it.
+// Sizes are in bytes.
+type PackageInfo struct {
+	DownloadSize  int64
+	InstalledSize int64
+}
+
+// PackageManager installs and inspects packages with one tool.
+type PackageManager interface {
+	Name() string
+	// Supports reports whether the backend is a sensible default on the OS.
+	Supports(info system.OSInfo) bool
+	// Detect reports whether the tool itself is installed.
+	Detect() bool
+	// Bootstrap returns the command that installs the tool, or nil when it
+	// ships with the OS.
+	Bootstrap() *exec.Cmd
+	// IsInstalled reports which of the packages are installed.
+	IsInstalled(packages []string) (map[string]bool, error)
+	// Install returns the command that installs the packages in one go.
+	Install(packages []string) *exec.Cmd
+	// Remove returns the command that removes the packages. It may ask for
+	// confirmation.
+	Remove(packages []string) *exec.Cmd
+	// Info returns the sizes of the packages it knows about; the others are
+	// left out.
+	Info(packages []string) (map[string]PackageInfo, error)
+}
+
+// Preparer is implemented by backends that add repositories or refresh
+// their package index before installing.
+type Preparer interface {
+	// Prepare returns the command that readies the backend, or nil when there
+	// is nothing to do.
+	Prepare() *exec.Cmd
+}
+
+// Config holds the per-profile settings of the backends. Each backend reads
+// its own table and ignores the others.
+type Config struct {
+	Apt     AptConfig
+	Dnf     DnfConfig
+	Brew    BrewConfig
+	Flatpak FlatpakConfig
+}
+
+// Validate reports the malformed backend settings.
+func (c Config) Validate() error {
+	return errors.Join(c.Apt.Validate(), c.Dnf.Validate(), c.Brew.Validate(), c.Flatpak.Validate())
+}
+
+// Merge applies child on top of c, keeping c's entries first.
+func (c Config) Merge(child Config) Config {
+	return Config{
+		Apt:     c.Apt.Merge(child.Apt),
+		Dnf:     c.Dnf.Merge(child.Dnf),
+		Brew:    c.Brew.Merge(child.Brew),
+		Flatpak: c.Flatpak.Merge(child.Flatpak),
+	}
+}
+
+// Factory builds a backend that runs its commands through exec and is
+// configured by cfg.
+type Factory func(exec system.Executor, cfg Config) PackageManager
+
+type registration struct {
+	name    string
+	factory Factory
+}
+
+// lookPath finds the tools on PATH. Tests replace it.
+var lookPath = exec.LookPath
+
+// registry holds the backends in detection order: the first one that
+// supports the OS is the default.
+var registry []registration
+
+func init() {
+	Register(Yay, func(exec system.Executor, _ Config) PackageManager { return newAURHelper(Yay, exec) })
+	Register(Paru, func(exec system.Executor, _ Config) PackageManager { return newAURHelper(Paru, exec) })
+	Register(Pacman, func(exec system.Executor, _ Config) PackageManager { return &pacman{exec: exec} })
+	Register(Apt, func(exec system.Executor, cfg Config) PackageManager { return &apt{exec: exec, config: cfg.Apt} })
+	Register(Dnf, func(exec system.Executor, cfg Config) PackageManager { return &dnf{exec: exec, config: cfg.Dnf} })
+	Register(Homebrew, func(exec system.Executor, cfg Config) PackageManager { return &brew{exec: exec, config: cfg.Brew} })
+}
+
+// Register adds a backend, replacing any backend of the same name. New
+// backends are tried last when detecting the default.
+func Register(name string, factory Factory) {
+	if i := slices.IndexFunc(registry, func(r registration) bool { return r.name == name }); i >= 0 {
+		registry[i].factory = factory
+		return
+	}
+
+	registry = append(registry, registration{name: name, factory: factory})
+}
+
+// Names returns the registered backends in detection order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for _, r := range registry {
+		names = append(names, r.name)
+	}
+
+	return names
+}
+
+// New returns the backend with the given name.
+func New(name string, exec system.Executor, cfg Config) (PackageManager, error) {
+	for _, r := range registry {
+		if r.name == name {
+			return r.factory(exec, cfg), nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"unknown package manager %q (available: %s)",
+		name,
+		strings.Join(Names(), ", "),
+	)
+}
+
+// Detect returns the default backend for the OS: the first one that
+// supports it and is already installed, or else the first one that supports
+// it, to be bootstrapped. On Arch this honours an installed paru instead of
+// installing yay next to it.
+func Detect(info system.OSInfo, exec system.Executor, cfg Config) (PackageManager, error) {
+	var supported []PackageManager
+	for _, r := range registry {
+		if pm := r.factory(exec, cfg); pm.Supports(info) {
+			supported = append(supported, pm)
+		}
+	}
+
+	for _, pm := range supported {
+		if pm.Detect() {
+			return pm, nil
+		}
+	}
+	if len(supported) > 0 {
+		return supported[0], nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrUnsupported, info.Family, info.Distro)
+}
+
+// IsAURHelper reports whether the backend also builds packages from the AUR.
+func IsAURHelper(pm PackageManager) bool {
+	_, ok := pm.(*aurHelper)
+	return ok
+}
+
+// Repo returns the backend that installs official repo packages for pm:
+// pacman for AUR helpers, pm itself otherwise.
+func Repo(pm PackageManager) PackageManager {
+	if h, ok := pm.(*aurHelper); ok {
+		return &h.pacman
+	}
+
+	return pm
+}
+
+// Setup returns the command that gets pm ready to install: it bootstraps the
+// tool when it is missing, then prepares it. It is nil when pm is ready.
+func Setup(pm PackageManager) *exec.Cmd {
+	var steps []*exec.Cmd
+	if !pm.Detect() {
+		if cmd := pm.Bootstrap(); cmd != nil {
+			steps = append(steps, cmd)
+		}
+	}
+	if p, ok := pm.(Preparer); ok {
+		if cmd := p.Prepare(); cmd != nil {
+			steps = append(steps, cmd)
+		}
+	}
+
+	return chain(steps)
+}
+
+// chain runs the commands one after the other, stopping at the first that
+// fails. It is nil without commands.
+func chain(steps []*exec.Cmd) *exec.Cmd {
+	switch len(steps) {
+	case 0:
+		return nil
+	case 1:
+		return steps[0]
+	}
+
+	lines := make([]string, 0, len(steps))
+	for _, cmd := range steps {
+		lines = append(lines, shellJoin(cmd.Args))
+	}
+	return exec.Command("bash", "-c", strings.Join(lines, " && "))
+}
+
+// shellJoin quotes args for a shell script.
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// installed runs a command that lists installed packages, one per line, and
+// picks the requested ones from its output.
+func installed(executor system.Executor, cmd *exec.Cmd, packages []string) (map[string]bool, error) {
+	output, err := executor.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("could not list installed packages: %w", err)
+	}
+
+	all := make(map[string]bool)
+	for _, name := range strings.Fields(string(output)) {
+		all[name] = true
+	}
+
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		if all[pkg] {
+			found[pkg] = true
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,245 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"errors"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type mockExecutor struct {
+	output     []byte
+	outputErr  error
+	outputFunc func(cmd *exec.Cmd) ([]byte, error)
+	commands   []string
+}
+
+func (m *mockExecutor) Run(cmd *exec.Cmd) error { return nil }
+func (m *mockExecutor) RunPiped(cmd1 *exec.Cmd, cmd2 *exec.Cmd) error {
+	return nil
+}
+func (m *mockExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
+	m.commands = append(m.commands, strings.Join(cmd.Args, " "))
+	if m.outputFunc != nil {
+		return m.outputFunc(cmd)
+	}
+	return m.output, m.outputErr
+}
+func (m *mockExecutor) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.Output(cmd)
+}
+func (m *mockExecutor) IsRoot() bool  { return false }
+func (m *mockExecutor) CanSudo() bool { return true }
+
+// stubLookPath makes only the given tools appear installed.
+func stubLookPath(t *testing.T, tools ...string) {
+	t.Helper()
+
+	original := lookPath
+	lookPath = func(file string) (string, error) {
+		if slices.Contains(tools, file) {
+			return "/usr/bin/" + file, nil
+		}
+		return "", exec.ErrNotFound
+	}
+	t.Cleanup(func() { lookPath = original })
+}
+
+func TestNew(t *testing.T) {
+	t.Run("it builds every registered backend", func(t *testing.T) {
+		for _, name := range Names() {
+			pm, err := New(name, &mockExecutor{}, Config{})
+			if err != nil {
+				t.Fatalf("New(%q) returned %v", name, err)
+			}
+			if pm.Name() != name {
+				t.Errorf("New(%q) built %q", name, pm.Name())
+			}
+		}
+	})
+
+	t.Run("it lists the available backends for unknown names", func(t *testing.T) {
+		_, err := New("zypper", &mockExecutor{}, Config{})
+		if err == nil || !strings.Contains(err.Error(), "pacman") {
+			t.Errorf("Expected an error listing the backends, but got %v", err)
+		}
+	})
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		info system.OSInfo
+		want string
+	}{
+		{system.OSInfo{Family: "linux", Distro: "arch"}, Yay},
+		{system.OSInfo{Family: "linux", Distro: "endeavouros", Like: []string{"arch"}}, Yay},
+		{system.OSInfo{Family: "linux", Distro: "ubuntu", Like: []string{"debian"}}, Apt},
+		{system.OSInfo{Family: "linux", Distro: "debian"}, Apt},
+		{system.OSInfo{Family: "linux", Distro: "fedora"}, Dnf},
+		{system.OSInfo{Family: "linux", Distro: "rocky", Like: []string{"rhel", "centos", "fedora"}}, Dnf},
+		{system.OSInfo{Family: "darwin"}, Homebrew},
+	}
+
+	for _, tt := range tests {
+		t.Run("it picks "+tt.want+" on "+tt.info.Family+" "+tt.info.Distro, func(t *testing.T) {
+			stubLookPath(t)
+
+			pm, err := Detect(tt.info, &mockExecutor{}, Config{})
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if pm.Name() != tt.want {
+				t.Errorf("Expected %s, but got %s", tt.want, pm.Name())
+			}
+		})
+	}
+
+	t.Run("it honours an AUR helper that is already installed", func(t *testing.T) {
+		stubLookPath(t, "pacman", "paru")
+
+		pm, err := Detect(system.OSInfo{Family: "linux", Distro: "arch"}, &mockExecutor{}, Config{})
+
+		if err != nil || pm.Name() != Paru {
+			t.Errorf("Expected paru, but got %v (%v)", pm, err)
+		}
+	})
+
+	t.Run("it never defaults to plain pacman", func(t *testing.T) {
+		stubLookPath(t, "pacman")
+
+		pm, err := Detect(system.OSInfo{Family: "linux", Distro: "arch"}, &mockExecutor{}, Config{})
+
+		if err != nil || pm.Name() != Yay {
+			t.Errorf("Expected yay to be bootstrapped, but got %v (%v)", pm, err)
+		}
+	})
+
+	t.Run("it returns ErrUnsupported when nothing fits", func(t *testing.T) {
+		_, err := Detect(system.OSInfo{Family: "windows"}, &mockExecutor{}, Config{})
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, but got %v", err)
+		}
+	})
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		install    string
+		remove     string
+		aurHelper  bool
+		bootstraps bool
+	}{
+		{Pacman, "sudo pacman -S --needed --noconfirm git zsh", "sudo pacman -Rns git zsh", false, false},
+		{Yay, "yay -S --needed --noconfirm git zsh", "sudo pacman -Rns git zsh", true, true},
+		{Paru, "paru -S --needed --noconfirm git zsh", "sudo pacman -Rns git zsh", true, true},
+		{Apt, "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y git zsh", "sudo apt-get remove --autoremove git zsh", false, false},
+		{Dnf, "sudo dnf install -y git zsh", "sudo dnf remove git zsh", false, false},
+		{Homebrew, "brew install --formula git zsh", "brew uninstall git zsh", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run("it builds the commands of "+tt.name, func(t *testing.T) {
+			pm, _ := New(tt.name, &mockExecutor{}, Config{})
+			packages := []string{"git", "zsh"}
+
+			if got := strings.Join(pm.Install(packages).Args, " "); got != tt.install {
+				t.Errorf("Expected install %q, but got %q", tt.install, got)
+			}
+			if got := strings.Join(pm.Remove(packages).Args, " "); got != tt.remove {
+				t.Errorf("Expected remove %q, but got %q", tt.remove, got)
+			}
+			if IsAURHelper(pm) != tt.aurHelper {
+				t.Errorf("Expected IsAURHelper to be %v", tt.aurHelper)
+			}
+			if (pm.Bootstrap() != nil) != tt.bootstraps {
+				t.Errorf("Expected a bootstrap command: %v", tt.bootstraps)
+			}
+		})
+	}
+
+	t.Run("AUR helpers install repo packages with pacman", func(t *testing.T) {
+		pm, _ := New(Paru, &mockExecutor{}, Config{})
+		if got := Repo(pm).Name(); got != Pacman {
+			t.Errorf("Expected pacman, but got %s", got)
+		}
+	})
+}
+
+func TestIsInstalled(t *testing.T) {
+	t.Run("it reports only the requested packages", func(t *testing.T) {
+		mockExec := &mockExecutor{output: []byte("base\ngit\nneovim\n")}
+		pm, _ := New(Pacman, mockExec, Config{})
+
+		got, err := pm.IsInstalled([]string{"git", "zsh"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(got, map[string]bool{"git": true}) {
+			t.Errorf("Expected only git, but got %v", got)
+		}
+		if !reflect.DeepEqual(mockExec.commands, []string{"pacman -Qq"}) {
+			t.Errorf("Unexpected commands %v", mockExec.commands)
+		}
+	})
+
+	t.Run("it returns an error when the list fails", func(t *testing.T) {
+		pm, _ := New(Homebrew, &mockExecutor{outputErr: errors.New("no brew")}, Config{})
+
+		if _, err := pm.IsInstalled([]string{"git"}); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
+
+const pacmanSiOutput = `Repository      : extra
+Name            : git
+Version         : 2.45.2-1
+Download Size   : 6.50 MiB
+Installed Size  : 27.00 MiB
+
+Repository      : extra
+Name            : zsh
+Download Size   : 512.00 KiB
+Installed Size  : 2.00 MiB
+`
+
+func TestParsePacmanSizes(t *testing.T) {
+	sizes := parsePacmanSizes(pacmanSiOutput)
+
+	want := map[string]PackageInfo{
+		"git": {DownloadSize: 6.5 * (1 << 20), InstalledSize: 27 * (1 << 20)},
+		"zsh": {DownloadSize: 512 * (1 << 10), InstalledSize: 2 * (1 << 20)},
+	}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("Expected %+v, but got %+v", want, sizes)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	t.Run("it is nil for a backend that is ready", func(t *testing.T) {
+		if cmd := Setup(&pacman{}); cmd != nil {
+			t.Errorf("Expected no setup, but got %v", cmd.Args)
+		}
+	})
+
+	t.Run("it prepares backends that need it", func(t *testing.T) {
+		cmd := Setup(&apt{})
+
+		if cmd == nil || !strings.Contains(strings.Join(cmd.Args, " "), "apt-get update") {
+			t.Errorf("Expected the apt index to be refreshed, got %v", cmd)
+		}
+	})
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin([]string{"bash", "-c", "echo 'hi'"})
+
+	if want := `'bash' '-c' 'echo '\''hi'\'''`; got != want {
+		t.Errorf("Expected %s, but got %s", want, got)
+	}
+}
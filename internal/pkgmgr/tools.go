@@ -0,0 +1,306 @@
+package pkgmgr
+
+import (
+	"archsetup/internal/system"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Tool installers: the language toolchains that install command-line tools
+// next to the system package manager.
+const (
+	Cargo = "cargo"
+	Go    = "go"
+	Npm   = "npm"
+	Pipx  = "pipx"
+)
+
+// toolFactories build the tool installers. They have no per-profile
+// settings.
+var toolFactories = map[string]func(exec system.Executor) PackageManager{
+	Cargo: func(exec system.Executor) PackageManager { return &cargo{exec: exec} },
+	Go:    func(exec system.Executor) PackageManager { return &goInstall{exec: exec} },
+	Npm:   func(exec system.Executor) PackageManager { return &npm{exec: exec} },
+	Pipx:  func(exec system.Executor) PackageManager { return &pipx{exec: exec} },
+}
+
+// NewTool returns the tool installer with the given name. Tool installers
+// are never the system package manager: their toolchain comes from the
+// package list, so they do not bootstrap themselves.
+func NewTool(name string, exec system.Executor) (PackageManager, error) {
+	factory, ok := toolFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tool installer %q", name)
+	}
+
+	return factory(exec), nil
+}
+
+// toolVersion matches the version or extras that may follow a tool's name,
+// as in "ripgrep@14.1.0" or "black==24.1.0".
+var toolVersion = regexp.MustCompile(`[@=<>!~\[].*$`)
+
+// toolName strips the version from a cargo or pipx package.
+func toolName(pkg string) string {
+	return toolVersion.ReplaceAllString(pkg, "")
+}
+
+// cargo installs crates with `cargo install`.
+type cargo struct {
+	exec system.Executor
+}
+
+func (c *cargo) Name() string                { return Cargo }
+func (c *cargo) Supports(system.OSInfo) bool { return true }
+func (c *cargo) Detect() bool                { return toolDetect("cargo") }
+func (c *cargo) Bootstrap() *exec.Cmd        { return nil }
+
+// IsInstalled reads `cargo install --list`: a "name version:" line per
+// crate, followed by its binaries, indented.
+func (c *cargo) IsInstalled(packages []string) (map[string]bool, error) {
+	output, err := c.exec.Output(exec.Command("cargo", "install", "--list"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list installed crates: %w", err)
+	}
+
+	all := make(map[string]bool)
+	for line := range strings.Lines(string(output)) {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(line, " ") {
+			all[fields[0]] = true
+		}
+	}
+
+	return pickInstalled(packages, all, toolName), nil
+}
+
+func (c *cargo) Install(packages []string) *exec.Cmd {
+	return exec.Command("cargo", append([]string{"install"}, packages...)...)
+}
+
+func (c *cargo) Remove(packages []string) *exec.Cmd {
+	return exec.Command("cargo", append([]string{"uninstall"}, mapNames(packages, toolName)...)...)
+}
+
+func (c *cargo) Info([]string) (map[string]PackageInfo, error) { return map[string]PackageInfo{}, nil }
+
+// goInstall installs Go commands with `go install`. Packages are import
+// paths; without a version they install @latest.
+type goInstall struct {
+	exec system.Executor
+}
+
+func (g *goInstall) Name() string                { return Go }
+func (g *goInstall) Supports(system.OSInfo) bool { return true }
+func (g *goInstall) Detect() bool                { return toolDetect("go") }
+func (g *goInstall) Bootstrap() *exec.Cmd        { return nil }
+
+// IsInstalled looks for the binaries in GOBIN, or else in the bin directory
+// of the first GOPATH entry; go keeps no list of what it installed.
+func (g *goInstall) IsInstalled(packages []string) (map[string]bool, error) {
+	bin, err := g.binDir()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		if _, err := os.Stat(filepath.Join(bin, goBinary(pkg))); err == nil {
+			found[pkg] = true
+		}
+	}
+
+	return found, nil
+}
+
+// Install runs one `go install` per package: go only installs several
+// versioned packages at once when they share a module.
+func (g *goInstall) Install(packages []string) *exec.Cmd {
+	steps := make([]*exec.Cmd, 0, len(packages))
+	for _, pkg := range packages {
+		if !strings.Contains(pkg, "@") {
+			pkg += "@latest"
+		}
+		steps = append(steps, exec.Command("go", "install", pkg))
+	}
+
+	return chain(steps)
+}
+
+// Remove deletes the binaries, which is all `go install` leaves behind.
+func (g *goInstall) Remove(packages []string) *exec.Cmd {
+	const script = `bin=$(go env GOBIN)
+[ -n "$bin" ] || bin=$(go env GOPATH | cut -d: -f1)/bin
+for b in "$@"; do rm -f "$bin/$b"; done`
+
+	return exec.Command("bash", append([]string{"-c", script, "bash"}, mapNames(packages, goBinary)...)...)
+}
+
+func (g *goInstall) Info([]string) (map[string]PackageInfo, error) {
+	return map[string]PackageInfo{}, nil
+}
+
+func (g *goInstall) binDir() (string, error) {
+	output, err := g.exec.Output(exec.Command("go", "env", "GOBIN", "GOPATH"))
+	if err != nil {
+		return "", fmt.Errorf("could not find the go bin directory: %w", err)
+	}
+
+	// An unset GOBIN is an empty first line.
+	gobin, gopath, _ := strings.Cut(string(output), "\n")
+	if gobin = strings.TrimSpace(gobin); gobin != "" {
+		return gobin, nil
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(gopath), string(os.PathListSeparator))
+	return filepath.Join(first, "bin"), nil
+}
+
+// goMajorVersion matches the /vN suffix of a module path, which is not part
+// of the binary's name.
+var goMajorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// goBinary returns the name of the binary `go install` builds for pkg.
+func goBinary(pkg string) string {
+	pkg, _, _ = strings.Cut(pkg, "@")
+	name := path.Base(pkg)
+	if goMajorVersion.MatchString(name) {
+		name = path.Base(path.Dir(pkg))
+	}
+
+	return name
+}
+
+// npm installs Node packages globally with `npm install -g`.
+type npm struct {
+	exec system.Executor
+}
+
+func (n *npm) Name() string                { return Npm }
+func (n *npm) Supports(system.OSInfo) bool { return true }
+func (n *npm) Detect() bool                { return toolDetect("npm") }
+func (n *npm) Bootstrap() *exec.Cmd        { return nil }
+
+// IsInstalled reads the global packages from `npm ls -g`.
+func (n *npm) IsInstalled(packages []string) (map[string]bool, error) {
+	// npm ls exits non-zero on problems such as extraneous packages, but
+	// still lists everything.
+	output, err := n.exec.Output(exec.Command("npm", "ls", "-g", "--depth=0", "--json"))
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("could not list global npm packages: %w", err)
+	}
+	if err != nil {
+		log.Printf("pkgmgr: npm ls reported an error: %v", err)
+	}
+
+	var list struct {
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("invalid npm ls output: %w", err)
+	}
+
+	all := make(map[string]bool, len(list.Dependencies))
+	for name := range list.Dependencies {
+		all[name] = true
+	}
+
+	return pickInstalled(packages, all, npmName), nil
+}
+
+func (n *npm) Install(packages []string) *exec.Cmd {
+	return exec.Command("npm", append([]string{"install", "-g"}, packages...)...)
+}
+
+func (n *npm) Remove(packages []string) *exec.Cmd {
+	return exec.Command("npm", append([]string{"uninstall", "-g"}, mapNames(packages, npmName)...)...)
+}
+
+func (n *npm) Info([]string) (map[string]PackageInfo, error) { return map[string]PackageInfo{}, nil }
+
+// npmName strips the version from an npm package, keeping the @ of a scope
+// as in "@angular/cli@17".
+func npmName(pkg string) string {
+	if i := strings.LastIndex(pkg, "@"); i > 0 {
+		return pkg[:i]
+	}
+
+	return pkg
+}
+
+// pipx installs Python applications into their own virtualenvs.
+type pipx struct {
+	exec system.Executor
+}
+
+func (p *pipx) Name() string                { return Pipx }
+func (p *pipx) Supports(system.OSInfo) bool { return true }
+func (p *pipx) Detect() bool                { return toolDetect("pipx") }
+func (p *pipx) Bootstrap() *exec.Cmd        { return nil }
+
+// IsInstalled reads `pipx list --short`: "name version" per line.
+func (p *pipx) IsInstalled(packages []string) (map[string]bool, error) {
+	output, err := p.exec.Output(exec.Command("pipx", "list", "--short"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list pipx packages: %w", err)
+	}
+
+	all := make(map[string]bool)
+	for line := range strings.Lines(string(output)) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			all[strings.ToLower(fields[0])] = true
+		}
+	}
+
+	return pickInstalled(packages, all, func(pkg string) string {
+		return strings.ToLower(toolName(pkg))
+	}), nil
+}
+
+func (p *pipx) Install(packages []string) *exec.Cmd {
+	return exec.Command("pipx", append([]string{"install"}, packages...)...)
+}
+
+// Remove runs one `pipx uninstall` per package, which is all it takes.
+func (p *pipx) Remove(packages []string) *exec.Cmd {
+	steps := make([]*exec.Cmd, 0, len(packages))
+	for _, pkg := range packages {
+		steps = append(steps, exec.Command("pipx", "uninstall", toolName(pkg)))
+	}
+
+	return chain(steps)
+}
+
+func (p *pipx) Info([]string) (map[string]PackageInfo, error) { return map[string]PackageInfo{}, nil }
+
+func toolDetect(binary string) bool {
+	_, err := lookPath(binary)
+	return err == nil
+}
+
+// pickInstalled returns the packages whose name, as given by name, is in all.
+func pickInstalled(packages []string, all map[string]bool, name func(string) string) map[string]bool {
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		if all[name(pkg)] {
+			found[pkg] = true
+		}
+	}
+
+	return found
+}
+
+func mapNames(packages []string, name func(string) string) []string {
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, name(pkg))
+	}
+
+	return slices.Compact(names)
+}
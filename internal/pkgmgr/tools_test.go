@@ -0,0 +1,107 @@
+package pkgmgr
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTools_IsInstalled(t *testing.T) {
+	tests := []struct {
+		tool     string
+		output   string
+		packages []string
+		want     map[string]bool
+	}{
+		{
+			tool:     Cargo,
+			output:   "cargo-watch v8.5.2:\n    cargo-watch\nripgrep v14.1.0:\n    rg\n",
+			packages: []string{"ripgrep@14", "cargo-watch", "rg", "bat"},
+			want:     map[string]bool{"ripgrep@14": true, "cargo-watch": true},
+		},
+		{
+			tool:     Npm,
+			output:   `{"dependencies": {"typescript": {"version": "5.4.5"}, "@angular/cli": {"version": "17.3.0"}}}`,
+			packages: []string{"typescript", "@angular/cli@17", "prettier"},
+			want:     map[string]bool{"typescript": true, "@angular/cli@17": true},
+		},
+		{
+			tool:     Pipx,
+			output:   "black 24.4.2\nPoetry 1.8.3\n",
+			packages: []string{"black==24.4.2", "poetry", "ruff"},
+			want:     map[string]bool{"black==24.4.2": true, "poetry": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte(tt.output), nil
+			}}
+			tool, _ := NewTool(tt.tool, mockExec)
+
+			got, err := tool.IsInstalled(tt.packages)
+
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGoInstall(t *testing.T) {
+	t.Run("it looks for binaries in the GOPATH bin directory", func(t *testing.T) {
+		gopath := t.TempDir()
+		if err := os.Mkdir(filepath.Join(gopath, "bin"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(gopath, "bin", "gopls"), nil, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte("\n" + gopath + "\n"), nil
+		}}
+		tool, _ := NewTool(Go, mockExec)
+
+		got, err := tool.IsInstalled([]string{"golang.org/x/tools/gopls@latest", "mvdan.cc/gofumpt"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(got, map[string]bool{"golang.org/x/tools/gopls@latest": true}) {
+			t.Errorf("Expected gopls to be installed, but got %v", got)
+		}
+	})
+
+	t.Run("it installs each package at its latest version unless pinned", func(t *testing.T) {
+		tool, _ := NewTool(Go, &mockExecutor{})
+
+		cmd := tool.Install([]string{"mvdan.cc/gofumpt", "golang.org/x/tools/gopls@v0.16.0"})
+
+		want := "'go' 'install' 'mvdan.cc/gofumpt@latest' && 'go' 'install' 'golang.org/x/tools/gopls@v0.16.0'"
+		if got := strings.Join(cmd.Args, " "); got != "bash -c "+want {
+			t.Errorf("Expected %q, but got %q", want, got)
+		}
+	})
+}
+
+func TestGoBinary(t *testing.T) {
+	tests := map[string]string{
+		"golang.org/x/tools/gopls@latest":         "gopls",
+		"github.com/go-delve/delve/cmd/dlv":       "dlv",
+		"github.com/golangci/golangci-lint/v2":    "golangci-lint",
+		"github.com/golangci/golangci-lint/v2@v2": "golangci-lint",
+	}
+
+	for pkg, want := range tests {
+		if got := goBinary(pkg); got != want {
+			t.Errorf("goBinary(%q) = %q, want %q", pkg, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// brewfileString matches the quoted arguments of a Brewfile line.
+	brewfileString = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+	// brewfileMasID matches the app id of a mas line.
+	brewfileMasID = regexp.MustCompile(`id:\s*(\d+)`)
+)
+
+// brewfile is what BAS reads from a Brewfile.
+type brewfile struct {
+	Taps []string
+	// Entries are package list entries: formulae by name, casks and Mac App
+	// Store apps with their prefix.
+	Entries []string
+}
+
+// isBrewfile reports whether a package list is a Brewfile by its name:
+// "Brewfile" or anything ending in ".Brewfile". The profile's brewfile is a
+// Brewfile whatever it is called.
+func isBrewfile(path string) bool {
+	base := filepath.Base(path)
+	return base == "Brewfile" || strings.HasSuffix(base, ".Brewfile")
+}
+
+// parseBrewfile reads the tap, brew, cask and mas lines of a Brewfile, the
+// way `brew bundle` classifies them. Other entries, such as vscode, are
+// skipped.
+func parseBrewfile(r io.Reader, path string) (brewfile, error) {
+	var bf brewfile
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		directive, _, _ := strings.Cut(line, " ")
+		var args []string
+		for _, m := range brewfileString.FindAllStringSubmatch(line, -1) {
+			args = append(args, m[1]+m[2])
+		}
+
+		switch directive {
+		case "tap", "brew", "cask", "mas":
+			if len(args) == 0 || args[0] == "" {
+				return brewfile{}, fmt.Errorf("%s:%d: %s needs a quoted name", path, lineNo, directive)
+			}
+		default:
+			log.Printf("profiles: %s:%d: skipping unsupported Brewfile entry %q", path, lineNo, directive)
+			continue
+		}
+
+		switch directive {
+		case "tap":
+			// An optional second argument is the tap's git remote.
+			bf.Taps = append(bf.Taps, strings.Join(args[:min(len(args), 2)], " "))
+		case "brew":
+			bf.Entries = append(bf.Entries, args[0])
+		case "cask":
+			bf.Entries = append(bf.Entries, SourceCask+":"+args[0])
+		case "mas":
+			id := brewfileMasID.FindStringSubmatch(line)
+			if id == nil {
+				return brewfile{}, fmt.Errorf("%s:%d: mas %q needs an id", path, lineNo, args[0])
+			}
+			bf.Entries = append(bf.Entries, SourceMas+":"+id[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return brewfile{}, fmt.Errorf("error reading Brewfile: %s: %w", path, err)
+	}
+
+	return bf, nil
+}
+
+// BackendConfig returns the profile's backend settings, with the taps of its
+// Brewfiles added to the Homebrew ones so they are tapped before installing.
+func (s *Service) BackendConfig(dotfilesPath string, p Profile) (pkgmgr.Config, error) {
+	cfg := p.Backends()
+
+	for _, list := range p.PackageLists() {
+		if !list.Brewfile && !isBrewfile(list.Path) {
+			continue
+		}
+
+		fullPath := filepath.Join(dotfilesPath, list.Path)
+		file, err := s.fs.Open(fullPath)
+		if err != nil {
+			return pkgmgr.Config{}, fmt.Errorf("could not open Brewfile %s: %w", fullPath, err)
+		}
+		bf, err := parseBrewfile(file, fullPath)
+		file.Close()
+		if err != nil {
+			return pkgmgr.Config{}, err
+		}
+
+		cfg = cfg.Merge(pkgmgr.Config{Brew: pkgmgr.BrewConfig{Taps: bf.Taps}})
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,122 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testBrewfile = `# Taps first, like brew bundle
+tap "homebrew/cask-fonts"
+tap "me/tools", "https://example.com/me/homebrew-tools.git"
+
+brew "git"
+brew "postgresql@16", restart_service: true
+cask "firefox", args: { appdir: "~/Applications" }
+cask 'font-jetbrains-mono'
+mas "Xcode", id: 497799835
+vscode "golang.go"
+`
+
+func TestParseBrewfile(t *testing.T) {
+	t.Run("it classifies formulae, casks and apps", func(t *testing.T) {
+		bf, err := parseBrewfile(strings.NewReader(testBrewfile), "Brewfile")
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		wantTaps := []string{"homebrew/cask-fonts", "me/tools https://example.com/me/homebrew-tools.git"}
+		if !reflect.DeepEqual(bf.Taps, wantTaps) {
+			t.Errorf("Expected taps %v, but got %v", wantTaps, bf.Taps)
+		}
+		wantEntries := []string{"git", "postgresql@16", "cask:firefox", "cask:font-jetbrains-mono", "mas:497799835"}
+		if !reflect.DeepEqual(bf.Entries, wantEntries) {
+			t.Errorf("Expected entries %v, but got %v", wantEntries, bf.Entries)
+		}
+	})
+
+	t.Run("it needs an id for mas apps", func(t *testing.T) {
+		_, err := parseBrewfile(strings.NewReader(`mas "Xcode"`), "Brewfile")
+
+		if err == nil || !strings.Contains(err.Error(), "Brewfile:1") {
+			t.Errorf("Expected an error with the line, but got %v", err)
+		}
+	})
+}
+
+func TestIsBrewfile(t *testing.T) {
+	tests := map[string]bool{
+		"mac/Brewfile":      true,
+		"mac/work.Brewfile": true,
+		"mac/packages.txt":  false,
+		"Brewfile.lock":     false,
+	}
+
+	for path, want := range tests {
+		if got := isBrewfile(path); got != want {
+			t.Errorf("isBrewfile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestService_Brewfile(t *testing.T) {
+	mockFS := &mockFileSystem{openFiles: map[string]string{
+		"/dots/mac/base.txt": "stow\n",
+		"/dots/mac/Brewfile": testBrewfile,
+	}}
+	service := setupService(&mockExecutor{}, mockFS)
+	profile := Profile{
+		Path:     "mac/base.txt",
+		Brewfile: "mac/Brewfile",
+		Brew:     pkgmgr.BrewConfig{Taps: []string{"homebrew/cask-fonts", "me/extra"}},
+	}
+
+	t.Run("it installs the Brewfile after the package lists", func(t *testing.T) {
+		packages, err := service.LoadPackageLists("/dots", profile.PackageLists(), Machine{})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{"stow", "git", "postgresql@16", "cask:firefox", "cask:font-jetbrains-mono", "mas:497799835"}
+		if !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+	})
+
+	t.Run("it reads the brewfile as a Brewfile whatever it is called", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{"/dots/mac/bundle": testBrewfile}}
+		service := setupService(&mockExecutor{}, mockFS)
+		profile := Profile{Brewfile: "mac/bundle"}
+
+		packages, err := service.LoadPackageLists("/dots", profile.PackageLists(), Machine{})
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{"git", "postgresql@16", "cask:firefox", "cask:font-jetbrains-mono", "mas:497799835"}
+		if !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+
+		cfg, err := service.BackendConfig("/dots", profile)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !slices.Contains(cfg.Brew.Taps, "me/tools https://example.com/me/homebrew-tools.git") {
+			t.Errorf("Expected the Brewfile's taps, but got %v", cfg.Brew.Taps)
+		}
+	})
+
+	t.Run("it adds the Brewfile taps to the profile's", func(t *testing.T) {
+		cfg, err := service.BackendConfig("/dots", profile)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{"homebrew/cask-fonts", "me/extra", "me/tools https://example.com/me/homebrew-tools.git"}
+		if !reflect.DeepEqual(cfg.Brew.Taps, want) {
+			t.Errorf("Expected %v, but got %v", want, cfg.Brew.Taps)
+		}
+	})
+}
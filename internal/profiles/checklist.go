@@ -0,0 +1,147 @@
+package profiles
+
+import (
+	"archsetup/internal/styles"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// packageDelegate renders one line per package with its checkbox.
+type packageDelegate struct {
+	deselected map[string]bool
+}
+
+func (d packageDelegate) Height() int                               { return 1 }
+func (d packageDelegate) Spacing() int                              { return 0 }
+func (d packageDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+func (d packageDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(packageItem)
+	if !ok {
+		return
+	}
+
+	box := "[x]"
+	if d.deselected[i.name] {
+		box = "[ ]"
+	}
+
+	var tag string
+	switch i.source {
+	case SourceUnknown:
+		tag = " " + styles.ErrorStyle.Render("(not found)")
+	case "":
+	default:
+		tag = " " + styles.SubtleTextStyle.Render("("+i.source+")")
+	}
+
+	line := fmt.Sprintf("%s %s", box, i.name)
+	if index == m.Index() {
+		line = styles.TitleStyle.Render("» " + line)
+	} else {
+		line = styles.NormalTextStyle.Render("  " + line)
+	}
+	fmt.Fprint(w, line+tag)
+}
+
+func (m *Model) newPackageList() list.Model {
+	items := make([]list.Item, len(m.profilePackages))
+	for i, pkg := range m.profilePackages {
+		items[i] = packageItem{name: pkg, source: m.packageSets.Source(pkg)}
+	}
+
+	l := list.New(items, packageDelegate{deselected: m.deselected}, m.width, m.height-8)
+	l.SetShowTitle(false)
+	l.SetShowHelp(false)
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+
+	return l
+}
+
+func (m *Model) handleConfirmationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// While typing a search, every key goes to the filter input.
+	if m.packageList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.packageList, cmd = m.packageList.Update(msg)
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Toggle):
+		if item, ok := m.packageList.SelectedItem().(packageItem); ok {
+			m.deselected[item.name] = !m.deselected[item.name]
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Enter):
+		return m, m.previewPlan()
+
+	case key.Matches(msg, m.keys.Back):
+		if m.packageList.FilterState() == list.FilterApplied {
+			m.packageList.ResetFilter()
+			return m, nil
+		}
+		m.nav.Reset(selectOptionPhase)
+		return m, nil
+	}
+
+	// Delegate other keys to the list for moving around and searching.
+	var cmd tea.Cmd
+	m.packageList, cmd = m.packageList.Update(msg)
+	return m, cmd
+}
+
+// selectedPackages returns the packages left ticked, in list order.
+func (m *Model) selectedPackages() []string {
+	var selected []string
+	for _, pkg := range m.profilePackages {
+		if !m.deselected[pkg] {
+			selected = append(selected, pkg)
+		}
+	}
+
+	if skipped := len(m.profilePackages) - len(selected); skipped > 0 {
+		log.Printf("profiles: %d packages unticked", skipped)
+	}
+
+	return selected
+}
+
+func (m *Model) checklistView() string {
+	header := fmt.Sprintf("Ready to install profile '%s'?", m.selectedProfile.Name)
+
+	total := len(m.profilePackages)
+	summary := fmt.Sprintf("%d of %d packages selected", total-m.deselectedCount(), total)
+
+	lines := []string{styles.TitleStyle.Render(header), summary}
+	if unknown := m.packageSets.Unknown; len(unknown) > 0 {
+		lines = append(lines, styles.ErrorStyle.Render(
+			"Not found in the repos or the AUR: "+strings.Join(unknown, ", "),
+		))
+	}
+
+	help := styles.SubtleTextStyle.Render(
+		"Space: toggle · /: search · Enter: review plan · Esc: back",
+	)
+	lines = append(lines, styles.BlurredBorderStyle.Render(m.packageList.View()), help)
+
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m *Model) deselectedCount() int {
+	count := 0
+	for _, pkg := range m.profilePackages {
+		if m.deselected[pkg] {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,109 @@
+package profiles
+
+import (
+	"archsetup/internal/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func setupChecklistModel(packages ...string) *Model {
+	service := setupService(&mockExecutor{}, &mockFileSystem{})
+	m := New(types.DefaultKeys(), service, nil).(*Model)
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	m.Update(packagesLoadedMsg{packages: packages})
+
+	return m
+}
+
+// typeKeys sends s key by key, feeding the list's filter results back like
+// the Bubble Tea runtime would.
+func typeKeys(m *Model, s string) {
+	for _, r := range s {
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		feedFilterMatches(m, cmd)
+	}
+}
+
+func feedFilterMatches(m *Model, cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+
+	switch msg := cmd().(type) {
+	case list.FilterMatchesMsg:
+		m.Update(msg)
+	case tea.BatchMsg:
+		for _, c := range msg {
+			feedFilterMatches(m, c)
+		}
+	}
+}
+
+func TestModel_PackageChecklist(t *testing.T) {
+	t.Run("unticked packages are not installed", func(t *testing.T) {
+		m := setupChecklistModel("git", "blender", "zsh")
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+		if !reflect.DeepEqual(m.toInstall, []string{"git", "zsh"}) {
+			t.Errorf("Expected [git zsh], got %v", m.toInstall)
+		}
+		if m.nav.Current() != planningPhase {
+			t.Errorf("Expected the plan to be worked out, got phase %v", m.nav.Current())
+		}
+	})
+
+	t.Run("packages can be found by searching", func(t *testing.T) {
+		m := setupChecklistModel("git", "zsh", "blender")
+		typeKeys(m, "/ble")
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter}) // apply the filter
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+
+		if !m.deselected["blender"] {
+			t.Fatalf("Expected blender to be unticked, got %v", m.deselected)
+		}
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEscape}) // clear the filter
+		if m.nav.Current() != confirmationPhase {
+			t.Errorf("Expected Esc to only clear the search, got phase %v", m.nav.Current())
+		}
+	})
+
+	t.Run("ticking a package again brings it back", func(t *testing.T) {
+		m := setupChecklistModel("git", "blender")
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+
+		if got := m.selectedPackages(); !reflect.DeepEqual(got, []string{"git", "blender"}) {
+			t.Errorf("Expected every package selected, got %v", got)
+		}
+	})
+
+	t.Run("going back from the plan keeps the whole list", func(t *testing.T) {
+		m := setupChecklistModel("git", "blender", "zsh")
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m.Update(planReadyMsg{})
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+		if m.nav.Current() != confirmationPhase {
+			t.Fatalf("Expected to be back on the checklist, got phase %v", m.nav.Current())
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+
+		if !strings.Contains(m.checklistView(), "3 of 3 packages selected") {
+			t.Errorf("Expected all 3 packages selected, got\n%s", m.checklistView())
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if !reflect.DeepEqual(m.toInstall, []string{"git", "blender", "zsh"}) {
+			t.Errorf("Expected [git blender zsh], got %v", m.toInstall)
+		}
+	})
+}
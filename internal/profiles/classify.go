@@ -0,0 +1,182 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+const aurRPCInfoURL = "https://aur.archlinux.org/rpc/v5/info"
+
+// aurLookupChunk keeps AUR RPC request URLs well below server limits.
+const aurLookupChunk = 100
+
+// ClassifyPackages sorts packages into official repo, AUR and unknown sets.
+// Only pacman-based package managers can look packages up; with the others
+// every unprefixed entry goes to the repo set, to be installed in one
+// transaction. Entries with an "aur:" prefix go straight to the AUR set;
+// other prefixed entries belong to their own backend and are left out of the
+// sets.
+func (s *Service) ClassifyPackages(packages []string) (PackageSets, error) {
+	pm, err := s.PackageManager()
+	if err != nil {
+		return PackageSets{}, err
+	}
+	if pkgmgr.Repo(pm).Name() != pkgmgr.Pacman {
+		return repoPackages(packages), nil
+	}
+
+	return s.classifyArchPackages(packages)
+}
+
+// repoPackages puts every unprefixed entry in the repo set.
+func repoPackages(packages []string) PackageSets {
+	var sets PackageSets
+	for _, pkg := range packages {
+		if prefix, _ := ParseEntry(pkg); prefix == "" {
+			sets.Repo = append(sets.Repo, pkg)
+		}
+	}
+
+	return sets
+}
+
+func (s *Service) classifyArchPackages(packages []string) (PackageSets, error) {
+	inRepo, err := s.syncDBPackages()
+	if err != nil {
+		return PackageSets{}, err
+	}
+
+	var sets PackageSets
+	var rest []string
+	for _, pkg := range packages {
+		prefix, _ := ParseEntry(pkg)
+		switch {
+		case prefix == SourceAUR:
+			sets.AUR = append(sets.AUR, pkg)
+		case prefix != "":
+			// Installed by its own backend, one by one.
+		case inRepo[pkg]:
+			sets.Repo = append(sets.Repo, pkg)
+		default:
+			rest = append(rest, pkg)
+		}
+	}
+
+	if len(rest) == 0 {
+		return sets, nil
+	}
+
+	inAUR, err := s.aurPackages(rest)
+	if err != nil {
+		// Without the AUR we cannot tell typos apart; let yay decide.
+		log.Printf("profiles: AUR lookup failed, assuming AUR: %v", err)
+		sets.AUR = append(sets.AUR, rest...)
+		return sets, nil
+	}
+
+	for _, pkg := range rest {
+		if inAUR[pkg] {
+			sets.AUR = append(sets.AUR, pkg)
+		} else {
+			sets.Unknown = append(sets.Unknown, pkg)
+		}
+	}
+
+	return sets, nil
+}
+
+// syncDBPackages returns the names of all packages and groups in the pacman
+// sync databases.
+func (s *Service) syncDBPackages() (map[string]bool, error) {
+	names := make(map[string]bool)
+
+	for _, args := range [][]string{{"-Slq"}, {"-Sgq"}} {
+		output, err := s.exec.Output(exec.Command("pacman", args...))
+		if err != nil {
+			return nil, fmt.Errorf("could not read the pacman sync database: %w", err)
+		}
+		for _, name := range strings.Fields(string(output)) {
+			names[name] = true
+		}
+	}
+
+	return names, nil
+}
+
+// aurPackages looks the packages up in the AUR and returns the ones found.
+func (s *Service) aurPackages(packages []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+
+	for chunk := range slices.Chunk(packages, aurLookupChunk) {
+		query := url.Values{"arg[]": chunk}
+		cmd := exec.Command("curl", "-fsSL", aurRPCInfoURL+"?"+query.Encode())
+
+		output, err := s.exec.Output(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("could not query the AUR: %w", err)
+		}
+
+		var resp struct {
+			Results []struct {
+				Name string `json:"Name"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(output, &resp); err != nil {
+			return nil, fmt.Errorf("invalid AUR response: %w", err)
+		}
+
+		for _, r := range resp.Results {
+			found[r.Name] = true
+		}
+	}
+
+	return found, nil
+}
+
+// Source reports which set pkg belongs to. Prefixed entries outside the sets
+// report their prefix; anything else reports "".
+func (p PackageSets) Source(pkg string) string {
+	switch {
+	case slices.Contains(p.Repo, pkg):
+		return SourceRepo
+	case slices.Contains(p.AUR, pkg):
+		return SourceAUR
+	case slices.Contains(p.Unknown, pkg):
+		return SourceUnknown
+	}
+
+	prefix, _ := ParseEntry(pkg)
+	return prefix
+}
+
+// Batches groups packages into one package manager transaction for the repo
+// set and one AUR helper transaction for the AUR set, keeping their order. Unknown and
+// unclassified packages, as well as entries for other backends, are left out
+// and get installed one by one.
+func (p PackageSets) Batches(packages []string) []InstallBatch {
+	var repo, aur []string
+	for _, pkg := range packages {
+		switch {
+		case slices.Contains(p.Repo, pkg):
+			repo = append(repo, pkg)
+		case slices.Contains(p.AUR, pkg):
+			aur = append(aur, pkg)
+		}
+	}
+
+	var batches []InstallBatch
+	if len(repo) > 0 {
+		batches = append(batches, InstallBatch{Source: SourceRepo, Packages: repo})
+	}
+	if len(aur) > 0 {
+		batches = append(batches, InstallBatch{Source: SourceAUR, Packages: aur})
+	}
+
+	return batches
+}
@@ -0,0 +1,161 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// archOutputs fakes pacman and the AUR RPC for the classification tests.
+func archOutputs(repo, groups, aur string, aurErr error) func(cmd *exec.Cmd) ([]byte, error) {
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		switch {
+		case cmd.Args[0] == "pacman" && cmd.Args[1] == "-Slq":
+			return []byte(repo), nil
+		case cmd.Args[0] == "pacman" && cmd.Args[1] == "-Sgq":
+			return []byte(groups), nil
+		case cmd.Args[0] == "curl":
+			return []byte(aur), aurErr
+		}
+		return nil, errors.New("unexpected command")
+	}
+}
+
+func TestService_ClassifyArchPackages(t *testing.T) {
+	t.Run("it splits packages into repo, AUR and unknown sets", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: archOutputs(
+			"git\nzsh\n",
+			"base-devel\n",
+			`{"resultcount":1,"results":[{"Name":"yay-bin"}]}`,
+			nil,
+		)}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		sets, err := service.classifyArchPackages(
+			[]string{"git", "yay-bin", "base-devel", "gti", "zsh"},
+		)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := PackageSets{
+			Repo:    []string{"git", "base-devel", "zsh"},
+			AUR:     []string{"yay-bin"},
+			Unknown: []string{"gti"},
+		}
+		if !reflect.DeepEqual(sets, want) {
+			t.Errorf("Expected %+v, but got %+v", want, sets)
+		}
+	})
+
+	t.Run("it routes prefixed entries without looking them up", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: archOutputs(
+			"git\n", "", `{"resultcount":0,"results":[]}`, nil,
+		)}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		sets, err := service.classifyArchPackages(
+			[]string{"git", "aur:spotify", "flatpak:com.discordapp.Discord"},
+		)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := PackageSets{Repo: []string{"git"}, AUR: []string{"aur:spotify"}}
+		if !reflect.DeepEqual(sets, want) {
+			t.Errorf("Expected %+v, but got %+v", want, sets)
+		}
+		if got := sets.Source("flatpak:com.discordapp.Discord"); got != SourceFlatpak {
+			t.Errorf("Expected source %q, but got %q", SourceFlatpak, got)
+		}
+	})
+
+	t.Run("it assumes AUR when the AUR cannot be reached", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: archOutputs(
+			"git\n", "", "", errors.New("offline"),
+		)}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		sets, err := service.classifyArchPackages([]string{"git", "spotify"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(sets.AUR, []string{"spotify"}) || len(sets.Unknown) != 0 {
+			t.Errorf("Expected spotify to be treated as AUR, but got %+v", sets)
+		}
+	})
+
+	t.Run("it returns an error if the sync database cannot be read", func(t *testing.T) {
+		mockExec := &mockExecutor{outputErr: errors.New("pacman missing")}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		if _, err := service.classifyArchPackages([]string{"git"}); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestService_ClassifyPackages(t *testing.T) {
+	t.Run("it puts unprefixed packages in the repo set without pacman", func(t *testing.T) {
+		service := setupService(&mockExecutor{outputErr: errors.New("unexpected command")}, &mockFileSystem{})
+		if err := service.UsePackageManager(pkgmgr.Apt, pkgmgr.Config{}); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		sets, err := service.ClassifyPackages([]string{"git", "flatpak:com.spotify.Client", "zsh"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := PackageSets{Repo: []string{"git", "zsh"}}
+		if !reflect.DeepEqual(sets, want) {
+			t.Errorf("Expected %+v, but got %+v", want, sets)
+		}
+	})
+
+	t.Run("it returns an error without a package manager", func(t *testing.T) {
+		service := setupService(&mockExecutor{}, &mockFileSystem{})
+		service.managerName = "nope"
+
+		if _, err := service.ClassifyPackages([]string{"git"}); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestPackageSets_Batches(t *testing.T) {
+	sets := PackageSets{
+		Repo:    []string{"git", "zsh"},
+		AUR:     []string{"yay-bin"},
+		Unknown: []string{"gti"},
+	}
+
+	t.Run("it makes one batch per source and leaves unknown packages out", func(t *testing.T) {
+		batches := sets.Batches([]string{"yay-bin", "git", "gti", "zsh"})
+
+		want := []InstallBatch{
+			{Source: SourceRepo, Packages: []string{"git", "zsh"}},
+			{Source: SourceAUR, Packages: []string{"yay-bin"}},
+		}
+		if !reflect.DeepEqual(batches, want) {
+			t.Errorf("Expected %+v, but got %+v", want, batches)
+		}
+	})
+
+	t.Run("it only batches the given packages", func(t *testing.T) {
+		batches := sets.Batches([]string{"zsh"})
+
+		if len(batches) != 1 || !reflect.DeepEqual(batches[0].Packages, []string{"zsh"}) {
+			t.Errorf("Expected a single batch with zsh, but got %+v", batches)
+		}
+	})
+
+	t.Run("it makes no batches for unclassified packages", func(t *testing.T) {
+		if batches := (PackageSets{}).Batches([]string{"git"}); len(batches) != 0 {
+			t.Errorf("Expected no batches, but got %+v", batches)
+		}
+	})
+}
@@ -1,16 +1,19 @@
 package profiles
 
 import (
-	"archsetup/internal/assert"
+	"archsetup/internal/pkgmgr"
 	"archsetup/internal/system"
-	"bufio"
+	"archsetup/internal/utils"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"regexp"
+	"slices"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	tea "github.com/charmbracelet/bubbletea"
@@ -18,6 +21,10 @@ import (
 
 const profilesFileName = "bas_settings.toml"
 
+// ErrProfilesNotFound is returned when the dotfiles repo has no
+// bas_settings.toml.
+var ErrProfilesNotFound = errors.New(profilesFileName + " not found")
+
 type profilesLoadedMsg struct {
 	Config Config
 }
@@ -26,6 +33,7 @@ type profilesNotFoundMsg struct{}
 
 type packagesLoadedMsg struct {
 	packages []string
+	sets     PackageSets
 }
 
 type installLogMsg struct {
@@ -39,8 +47,19 @@ type installationFinishedMsg struct {
 type installStartedMsg struct{}
 
 type packageInstallResultMsg struct {
-	pkg string
-	err error
+	pkg    string
+	err    error
+	output string
+}
+
+type toolsCheckedMsg struct {
+	pending   []string
+	installed map[string]bool
+}
+
+type batchInstallResultMsg struct {
+	batch InstallBatch
+	err   error
 }
 
 type stowResultMsg struct {
@@ -55,11 +74,18 @@ type postInstallCompleteMsg struct {
 	err error
 }
 
+type logClosedMsg struct{ err error }
+
 type errMsg struct{ err error }
 
 type Service struct {
-	exec system.Executor
-	fs   system.FileSystem
+	exec  system.Executor
+	fs    system.FileSystem
+	facts func() system.Facts
+	// managerName and managerConfig select the package manager backend; see
+	// UsePackageManager.
+	managerName   string
+	managerConfig pkgmgr.Config
 }
 
 type startStreamingCmdMsg struct {
@@ -68,11 +94,11 @@ type startStreamingCmdMsg struct {
 	stderr io.ReadCloser
 }
 
-type yayCheckResultMsg struct {
+type pkgMgrCheckResultMsg struct {
 	isInstalled bool
 }
 
-type yayInstallResultMsg struct {
+type pkgMgrSetupResultMsg struct {
 	err error
 }
 
@@ -81,50 +107,102 @@ func NewService(
 	fs system.FileSystem,
 ) *Service {
 	return &Service{
-		exec: exec,
-		fs:   fs,
+		exec:  exec,
+		fs:    fs,
+		facts: sync.OnceValue(system.CurrentFacts),
 	}
 }
 
-func (s *Service) getProfilesCmd(dotfilesPath string) tea.Cmd {
-	return func() tea.Msg {
-		info, err := s.fs.Stat(dotfilesPath)
-		if s.fs.IsNotExist(err) {
-			return errMsg{
-				fmt.Errorf("dotfiles path does not exist: %s", dotfilesPath),
-			}
-		}
-		if err != nil {
-			return errMsg{
-				fmt.Errorf("error accessing dotfiles path: %w", err),
-			}
-		}
-		if !info.IsDir() {
-			return errMsg{
-				fmt.Errorf(
-					"dotfiles path is not a directory: %s",
-					dotfilesPath,
-				),
-			}
+// Facts returns the detected hardware facts. They are read once and cached.
+func (s *Service) Facts() system.Facts {
+	return s.facts()
+}
+
+// UsePackageManager selects the backend packages are installed with, as
+// named by a profile's package_manager, and configures it with the profile's
+// backend settings. An empty name selects the default for the OS.
+func (s *Service) UsePackageManager(name string, cfg pkgmgr.Config) error {
+	if name != "" {
+		if _, err := pkgmgr.New(name, s.exec, cfg); err != nil {
+			return err
 		}
+	}
 
-		configPath := filepath.Join(dotfilesPath, profilesFileName)
-		if _, err := s.fs.Stat(configPath); s.fs.IsNotExist(err) {
+	s.managerName = name
+	s.managerConfig = cfg
+	return nil
+}
+
+// PackageManager returns the selected backend, or the default for the OS.
+func (s *Service) PackageManager() (pkgmgr.PackageManager, error) {
+	if s.managerName != "" {
+		return pkgmgr.New(s.managerName, s.exec, s.managerConfig)
+	}
+
+	return pkgmgr.Detect(system.CurrentOSInfo(), s.exec, s.managerConfig)
+}
+
+// LoadConfig reads and parses bas_settings.toml from the dotfiles directory.
+// It returns ErrProfilesNotFound when the directory has no settings file.
+func (s *Service) LoadConfig(dotfilesPath string) (Config, error) {
+	info, err := s.fs.Stat(dotfilesPath)
+	if s.fs.IsNotExist(err) {
+		return Config{}, fmt.Errorf(
+			"dotfiles path does not exist: %s",
+			dotfilesPath,
+		)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("error accessing dotfiles path: %w", err)
+	}
+	if !info.IsDir() {
+		return Config{}, fmt.Errorf(
+			"dotfiles path is not a directory: %s",
+			dotfilesPath,
+		)
+	}
+
+	configPath := filepath.Join(dotfilesPath, profilesFileName)
+	if _, err := s.fs.Stat(configPath); s.fs.IsNotExist(err) {
+		return Config{}, ErrProfilesNotFound
+	}
+
+	data, err := s.fs.ReadFile(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf(
+			"could not read %s: %w",
+			profilesFileName,
+			err,
+		)
+	}
+
+	var cfg Config
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf(
+			"invalid %s format: %w",
+			profilesFileName,
+			err,
+		)
+	}
+
+	if err := cfg.resolveInheritance(); err != nil {
+		return Config{}, fmt.Errorf("invalid %s: %w", profilesFileName, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid %s: %w", profilesFileName, err)
+	}
+
+	return cfg, nil
+}
+
+func (s *Service) getProfilesCmd(dotfilesPath string) tea.Cmd {
+	return func() tea.Msg {
+		cfg, err := s.LoadConfig(dotfilesPath)
+		if errors.Is(err, ErrProfilesNotFound) {
 			return profilesNotFoundMsg{}
 		}
-
-		data, err := s.fs.ReadFile(configPath)
 		if err != nil {
-			return errMsg{
-				fmt.Errorf("could not read %s: %w", profilesFileName, err),
-			}
-		}
-
-		var cfg Config
-		if err := toml.Unmarshal(data, &cfg); err != nil {
-			return errMsg{
-				fmt.Errorf("invalid %s format: %w", profilesFileName, err),
-			}
+			return errMsg{err}
 		}
 
 		return profilesLoadedMsg{Config: cfg}
@@ -135,159 +213,347 @@ func (s *Service) getDefaultProfiles() []Profile {
 	return nil
 }
 
-func (s *Service) loadPackagesCmd(
+// LoadPackages reads a package list file relative to the dotfiles directory.
+// Blank lines and lines starting with '#' are ignored, "@include other.txt"
+// lines are replaced by the packages of that list, and lines whose
+// "# @when" condition does not hold on the machine are skipped.
+func (s *Service) LoadPackages(
 	dotfilesPath, profilePackagepath string,
-) tea.Cmd {
-	return func() tea.Msg {
-		fullPath := filepath.Join(dotfilesPath, profilePackagepath)
+	machine Machine,
+) ([]string, error) {
+	fullPath := filepath.Join(dotfilesPath, profilePackagepath)
+	return s.readPackageList(fullPath, isBrewfile(fullPath), machine, nil)
+}
 
-		file, err := s.fs.Open(fullPath)
+// LoadPackageLists reads several package lists in order and merges them,
+// keeping the first occurrence of every package.
+func (s *Service) LoadPackageLists(
+	dotfilesPath string,
+	lists []PackageList,
+	machine Machine,
+) ([]string, error) {
+	var packages []string
+	seen := make(map[string]bool)
+
+	for _, list := range lists {
+		fullPath := filepath.Join(dotfilesPath, list.Path)
+		listed, err := s.readPackageList(fullPath, list.Brewfile || isBrewfile(fullPath), machine, nil)
 		if err != nil {
-			return errMsg{
-				fmt.Errorf(
-					"could not open package list %s: %w",
-					fullPath,
-					err,
-				),
-			}
+			return nil, err
 		}
-		defer file.Close()
-
-		var packages []string
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" && !strings.HasPrefix(line, "#") {
-				packages = append(packages, line)
+
+		for _, pkg := range listed {
+			if !seen[pkg] {
+				seen[pkg] = true
+				packages = append(packages, pkg)
 			}
 		}
+	}
 
-		if err := scanner.Err(); err != nil {
-			return errMsg{
-				fmt.Errorf(
-					"error reading package list: %s: %w",
-					fullPath,
-					err,
-				),
-			}
+	return packages, nil
+}
+
+// LoadProfilePackages reads the profile's package lists, resolves logical
+// names through package_map.toml, and adds its tools at the end, so they are
+// installed after the system packages.
+func (s *Service) LoadProfilePackages(dotfilesPath string, p Profile) ([]string, error) {
+	packages, err := s.LoadPackageLists(dotfilesPath, p.PackageLists(), p.Machine(s.Facts()))
+	if err != nil {
+		return nil, err
+	}
+	packages, err = s.mapPackages(dotfilesPath, packages)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.MergeUnique(packages, p.Tools.Entries()), nil
+}
+
+func (s *Service) loadPackagesCmd(dotfilesPath string, p Profile) tea.Cmd {
+	return func() tea.Msg {
+		packages, err := s.LoadProfilePackages(dotfilesPath, p)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		sets, err := s.ClassifyPackages(packages)
+		if err != nil {
+			log.Printf("profiles: could not classify packages, installing one by one: %v", err)
 		}
 
-		return packagesLoadedMsg{packages: packages}
+		return packagesLoadedMsg{packages: packages, sets: sets}
 	}
 }
 
-// installPackagesCmd decides how to run yay:
-// - if root: run directly (streaming)
-// - else if sudo timestamp cached: sudo -n (streaming)
-// - else: interactive takeover so user can type password (no stream)
-func (s *Service) installPackageCmd(pkg string) tea.Cmd {
+// PackageInstallCommand builds the command that installs a single package
+// with the selected package manager, or with the backend named by its
+// prefix: Flatpak apps from the profile's remotes (flathub by default),
+// Homebrew casks, Mac App Store apps by id, or the tool installers.
+func (s *Service) PackageInstallCommand(pkg string) (*exec.Cmd, error) {
 	info := system.CurrentOSInfo()
-	var cmd *exec.Cmd
-
-	switch info.Family {
-	case "darwin":
-		sh := fmt.Sprintf(`brew list --formula %[1]s >/dev/null 2>&1 || brew install %[1]s || brew list --cask %[1]s >/dev/null 2>&1 || brew install --cask %[1]s`, pkg)
-		cmd = exec.Command("bash", "-lc", sh)
-
-	case "linux":
-		if isArchLike(info.Distro) {
-			scriptPath, err := s.createInstallRunner(pkg)
-			if err != nil {
-				return func() tea.Msg { return packageInstallResultMsg{pkg: pkg, err: err} }
-			}
 
-			// NOTE: the temp file is cleaned up by the runner itself or on reboot; avoid removing here
-			cmd = exec.Command(scriptPath)
-		} else {
-			return func() tea.Msg {
-				return packageInstallResultMsg{
-					pkg: pkg,
-					err: fmt.Errorf("unsupported Linux distro for package install: %s", info.Distro),
-				}
-			}
+	prefix, name := ParseEntry(pkg)
+	switch prefix {
+	case SourceFlatpak:
+		if info.Family != "linux" {
+			return nil, fmt.Errorf("flatpak packages are only supported on Linux: %s", pkg)
+		}
+		fp := s.sideBackend(prefix)
+		if !fp.Detect() {
+			return nil, fmt.Errorf("flatpak is not installed; add it to the package list: %s", pkg)
 		}
+		return fp.Install([]string{name}), nil
 
-	default:
-		return func() tea.Msg {
-			return packageInstallResultMsg{
-				pkg: pkg,
-				err: fmt.Errorf("unsupported OS: %s", info.Family),
-			}
+	case SourceCask:
+		if info.Family != "darwin" {
+			return nil, fmt.Errorf("cask packages are only supported on macOS: %s", pkg)
+		}
+		return exec.Command("brew", "install", "--cask", name), nil
+
+	case SourceMas:
+		if info.Family != "darwin" {
+			return nil, fmt.Errorf("mas apps are only supported on macOS: %s", pkg)
 		}
+		return exec.Command("mas", "install", name), nil
+
+	case SourcePip, SourceCargo, SourceGo, SourceNpm:
+		tool := s.sideBackend(prefix)
+		if !tool.Detect() {
+			return nil, fmt.Errorf("%s is not installed; add it to the package list: %s", tool.Name(), pkg)
+		}
+		return tool.Install([]string{name}), nil
 	}
 
-	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+	pm, err := s.PackageManager()
+	if err != nil {
+		return nil, err
+	}
+	if prefix == SourceAUR && !pkgmgr.IsAURHelper(pm) {
+		return nil, fmt.Errorf("%s cannot install AUR packages: %s", pm.Name(), pkg)
+	}
+
+	return pm.Install([]string{name}), nil
+}
+
+// InstalledTools returns the tool entries among packages that are already
+// installed. The tool installers have no equivalent of pacman's --needed, so
+// these are skipped instead of installed again.
+func (s *Service) InstalledTools(packages []string) map[string]bool {
+	tools := slices.DeleteFunc(slices.Clone(packages), func(pkg string) bool { return !IsTool(pkg) })
+	installed, errs := s.installedSideEntries(tools)
+	for _, err := range errs {
+		log.Printf("profiles: %v", err)
+	}
+
+	return installed
+}
+
+// checkToolsCmd looks up the installed tools among pending without blocking
+// the UI.
+func (s *Service) checkToolsCmd(pending []string) tea.Cmd {
+	return func() tea.Msg {
+		return toolsCheckedMsg{pending: pending, installed: s.InstalledTools(pending)}
+	}
+}
+
+// installPackageCmd hands the terminal over to the installer so that sudo
+// and the package manager can prompt the user when they need to.
+func (s *Service) installPackageCmd(pkg string) tea.Cmd {
+	cmd, err := s.PackageInstallCommand(pkg)
+	if err != nil {
+		return func() tea.Msg { return packageInstallResultMsg{pkg: pkg, err: err} }
+	}
+
+	captured := &capturingCommand{Cmd: cmd, output: &outputTail{limit: maxCapturedOutput}}
+	return tea.Exec(captured, func(err error) tea.Msg {
 		if err != nil {
-			return packageInstallResultMsg{pkg: pkg, err: err}
+			return packageInstallResultMsg{pkg: pkg, err: err, output: captured.output.String()}
 		}
 		return packageInstallResultMsg{pkg: pkg, err: nil}
 	})
 }
 
-func (s *Service) createInstallRunner(pkg string) (string, error) {
-	scriptFile, err := os.CreateTemp("", "archsetup-runner-*.sh")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp runner script: %w", err)
+// maxCapturedOutput is how much of an install's output is kept for the
+// retry screen; the end of the output is where the error is.
+const maxCapturedOutput = 64 * 1024
+
+// capturingCommand runs a command on the terminal like tea.ExecProcess does,
+// while keeping a copy of its output.
+type capturingCommand struct {
+	*exec.Cmd
+	output *outputTail
+}
+
+func (c *capturingCommand) SetStdin(r io.Reader) {
+	if c.Stdin == nil {
+		c.Stdin = r
+	}
+}
+
+func (c *capturingCommand) SetStdout(w io.Writer) {
+	c.Stdout = io.MultiWriter(w, c.output)
+}
+
+func (c *capturingCommand) SetStderr(w io.Writer) {
+	c.Stderr = io.MultiWriter(w, c.output)
+}
+
+// outputTail is a writer that keeps the last limit bytes written to it.
+type outputTail struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.limit {
+		t.buf = t.buf[len(t.buf)-t.limit:]
 	}
-	defer scriptFile.Close()
 
-	scriptContent := fmt.Sprintf(`#!/bin/sh
-set -e
-echo "--- Running installer for %s ---"
-yay -S --noconfirm --needed %s
-`, pkg, pkg)
+	return len(p), nil
+}
+
+func (t *outputTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	_, err = scriptFile.WriteString(scriptContent)
+	return string(t.buf)
+}
+
+// unsafeFileNameChars matches what may not go into a log file name, such as
+// the slashes of a go: entry.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// viewOutputCmd opens output in the user's pager ($PAGER, or less).
+func (s *Service) viewOutputCmd(name, output string) tea.Cmd {
+	name = unsafeFileNameChars.ReplaceAllString(name, "_")
+	f, err := os.CreateTemp("", "bas-"+name+"-*.log")
 	if err != nil {
-		return "", fmt.Errorf("failed to write to runner script: %w", err)
+		return func() tea.Msg { return logClosedMsg{err: err} }
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(output); err != nil {
+		return func() tea.Msg { return logClosedMsg{err: err} }
 	}
 
-	// Make the script executable.
-	if err := os.Chmod(scriptFile.Name(), 0755); err != nil {
-		return "", fmt.Errorf("failed to make runner script executable: %w", err)
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
 	}
 
-	log.Printf("profiles: Created runner script for package %s at %s", pkg, scriptFile.Name())
-	return scriptFile.Name(), nil
+	cmd := exec.Command("sh", "-c", pager+` "$1"`, "sh", f.Name())
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		os.Remove(f.Name())
+		return logClosedMsg{err: err}
+	})
 }
 
-func (s *Service) stowCmd(sourceDir string, stowDirs []string) tea.Cmd {
-	return func() tea.Msg {
-		if len(stowDirs) == 0 {
-			log.Println("profiles: No directories specified to stow.")
-			return stowResultMsg{err: nil}
+// UnbatchedPackages returns the packages not covered by any batch, in order.
+func UnbatchedPackages(packages []string, batches []InstallBatch) []string {
+	batched := make(map[string]bool)
+	for _, b := range batches {
+		for _, pkg := range b.Packages {
+			batched[pkg] = true
 		}
+	}
 
-		home, err := s.fs.UserHomeDir()
-		if err != nil {
-			return errMsg{fmt.Errorf("could not get user home dir: %w", err)}
+	var rest []string
+	for _, pkg := range packages {
+		if !batched[pkg] {
+			rest = append(rest, pkg)
 		}
+	}
 
-		args := []string{"-t", home, "-R"}
-		args = append(args, stowDirs...)
+	return rest
+}
 
-		cmd := exec.Command("stow", args...)
-		cmd.Dir = sourceDir
+// BatchInstallCommand builds the command that installs a whole batch in one
+// transaction: repo packages with the package manager (pacman behind an AUR
+// helper), AUR packages with the AUR helper.
+func (s *Service) BatchInstallCommand(batch InstallBatch) (*exec.Cmd, error) {
+	pm, err := s.PackageManager()
+	if err != nil {
+		return nil, err
+	}
 
-		if output, err := s.exec.CombinedOutput(cmd); err != nil {
-			return stowResultMsg{
-				err: fmt.Errorf(
-					"stow failed: %w\nOutput: %s",
-					err,
-					string(output),
-				),
-			}
+	switch batch.Source {
+	case SourceRepo:
+		pm = pkgmgr.Repo(pm)
+	case SourceAUR:
+		if !pkgmgr.IsAURHelper(pm) {
+			return nil, fmt.Errorf("%s cannot install AUR packages; set package_manager to yay or paru", pm.Name())
 		}
+	default:
+		return nil, fmt.Errorf("unknown package source: %s", batch.Source)
+	}
 
-		return stowResultMsg{err: nil}
+	names := make([]string, 0, len(batch.Packages))
+	for _, pkg := range batch.Packages {
+		names = append(names, PackageName(pkg))
 	}
+	return pm.Install(names), nil
 }
 
-func (s *Service) RunPostInstallCmd(
+// installBatchCmd hands the terminal over to the package manager, like
+// installPackageCmd does for single packages.
+func (s *Service) installBatchCmd(batch InstallBatch) tea.Cmd {
+	cmd, err := s.BatchInstallCommand(batch)
+	if err != nil {
+		return func() tea.Msg { return batchInstallResultMsg{batch: batch, err: err} }
+	}
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return batchInstallResultMsg{batch: batch, err: err}
+	})
+}
+
+// Stow symlinks the given directories of the dotfiles repo into $HOME.
+func (s *Service) Stow(sourceDir string, stowDirs []string) error {
+	if len(stowDirs) == 0 {
+		log.Println("profiles: No directories specified to stow.")
+		return nil
+	}
+
+	home, err := s.fs.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("could not get user home dir: %w", err)
+	}
+
+	args := []string{"-t", home, "-R"}
+	args = append(args, stowDirs...)
+
+	cmd := exec.Command("stow", args...)
+	cmd.Dir = sourceDir
+
+	if output, err := s.exec.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf(
+			"stow failed: %w\nOutput: %s",
+			err,
+			string(output),
+		)
+	}
+
+	return nil
+}
+
+func (s *Service) stowCmd(sourceDir string, stowDirs []string) tea.Cmd {
+	return func() tea.Msg {
+		return stowResultMsg{err: s.Stow(sourceDir, stowDirs)}
+	}
+}
+
+// BuildPostInstallCmd prepares the profile's post-install command to run in
+// its working directory with the given extra environment.
+func (s *Service) BuildPostInstallCmd(
 	dotfilesPath string,
 	cmd PostInstallCommand,
 	extraEnv map[string]string,
-) tea.Cmd {
+) *exec.Cmd {
 	workingDir := filepath.Join(dotfilesPath, cmd.WorkingDir)
 	log.Printf(
 		"Running post-install command '%s' in dir '%s'",
@@ -305,103 +571,51 @@ func (s *Service) RunPostInstallCmd(
 		execCmd.Env = append(execCmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	return execCmd
+}
+
+func (s *Service) RunPostInstallCmd(
+	dotfilesPath string,
+	cmd PostInstallCommand,
+	extraEnv map[string]string,
+) tea.Cmd {
+	execCmd := s.BuildPostInstallCmd(dotfilesPath, cmd, extraEnv)
+
 	return tea.ExecProcess(execCmd, func(err error) tea.Msg {
 		return postInstallCompleteMsg{err: err}
 	})
 }
 
-// On Arch Linux, we check/install yay. On MacOS we check/install Homebrew (+ ansible, stow).
-func (s *Service) CheckYayCmd() tea.Cmd {
-	return s.CheckPkgMgrCmd()
+// PackageManagerSetupCommand builds the command that gets the package
+// manager ready: installing it, e.g. yay on Arch or Homebrew on macOS, and
+// adding the profile's repositories. It is nil when there is nothing to do.
+func (s *Service) PackageManagerSetupCommand() (*exec.Cmd, error) {
+	pm, err := s.PackageManager()
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgmgr.Setup(pm), nil
 }
 
 func (s *Service) CheckPkgMgrCmd() tea.Cmd {
 	return func() tea.Msg {
-		info := system.CurrentOSInfo()
-		switch info.Family {
-		case "darwin":
-			_, err := exec.LookPath("brew")
-			return yayCheckResultMsg{isInstalled: err == nil}
-		case "linux":
-			if isArchLike(info.Distro) {
-				_, err := exec.LookPath("yay")
-				return yayCheckResultMsg{isInstalled: err == nil}
-			}
-
-			assert.Fail("Unsupported Linux for package (for now)")
+		setup, err := s.PackageManagerSetupCommand()
+		if err != nil {
+			return errMsg{err}
 		}
 
-		assert.Fail("Unsupported OS for package (for now)")
-
-		return yayCheckResultMsg{isInstalled: true} // unreachable
+		return pkgMgrCheckResultMsg{isInstalled: setup == nil}
 	}
 }
 
-func isArchLike(distro string) bool {
-	d := strings.ToLower(distro)
-
-	switch d {
-	case "arch", "archlinux", "manjaro", "endeavouros", "garuda", "archarm":
-		return true
+func (s *Service) InstallPkgMgrCmd() tea.Cmd {
+	setup, err := s.PackageManagerSetupCommand()
+	if err != nil || setup == nil {
+		return func() tea.Msg { return pkgMgrSetupResultMsg{err: err} }
 	}
 
-	return false
-}
-
-// Add this new method to your Service
-func (s *Service) InstallYayCmd() tea.Cmd {
-	// Commands to install git and base-devel, clone yay, and run makepkg
-	script := `
-		set -e
-		echo "--- Installing dependencies for yay (git, base-devel) ---"
-		sudo pacman -S --noconfirm --needed git base-devel
-		
-		echo "--- Cloning yay from AUR ---"
-		cd /tmp
-		if [ -d "yay" ]; then rm -rf yay; fi
-		git clone https://aur.archlinux.org/yay.git
-		
-		echo "--- Building and installing yay ---"
-		cd yay
-		makepkg -si --noconfirm
-		
-		echo "--- Cleaning up ---"
-		cd /tmp
-		rm -rf yay
-		
-		echo "--- yay installation complete! ---"
-	`
-	cmd := exec.Command("bash", "-c", script)
-
-	// Use tea.ExecProcess to get a nice streaming output in the UI
-	return tea.ExecProcess(cmd, func(err error) tea.Msg {
-		return yayInstallResultMsg{err: err}
-	})
-}
-
-func (s *Service) InstallPkgMgrCmd() tea.Cmd {
-	info := system.CurrentOSInfo()
-	if info.Family == "darwin" {
-		script := `
-            set -e
-            if ! command -v brew >/dev/null 2>&1; then
-              echo '--- Installing Homebrew ---'
-              /bin/bash -c "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"
-              test -x /opt/homebrew/bin/brew && eval "$(/opt/homebrew/bin/brew shellenv)"
-              test -x /usr/local/bin/brew   && eval "$(/usr/local/bin/brew shellenv)"
-            fi
-            echo '--- Ensuring prerequisites on macOS ---'
-            brew install ansible stow
-        `
-		return tea.ExecProcess(exec.Command("bash", "-c", script), func(err error) tea.Msg {
-			return yayInstallResultMsg{err: err}
-		})
-	}
-	if info.Family == "linux" && isArchLike(info.Distro) {
-		return s.InstallYayCmd() // your existing yay bootstrap
-	}
-	// other Linux: nothing to install (we won't try packages)
-	return tea.ExecProcess(exec.Command("bash", "-c", "true"), func(err error) tea.Msg {
-		return yayInstallResultMsg{err: nil}
+	return tea.ExecProcess(setup, func(err error) tea.Msg {
+		return pkgMgrSetupResultMsg{err: err}
 	})
 }
@@ -1,11 +1,15 @@
 package profiles
 
 import (
+	"archsetup/internal/pkgmgr"
+	"archsetup/internal/system"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -18,18 +22,28 @@ type mockExecutor struct {
 	canSudo           bool
 	combinedOutput    []byte
 	combinedOutputErr error
+	output            []byte
+	outputErr         error
+	outputFunc        func(cmd *exec.Cmd) ([]byte, error)
+	// commands records what Run and CombinedOutput were given.
+	commands []string
 }
 
 func (m *mockExecutor) Run(cmd *exec.Cmd) error {
+	m.commands = append(m.commands, strings.Join(cmd.Args, " "))
 	return nil
 }
 func (m *mockExecutor) RunPiped(cmd1 *exec.Cmd, cmd2 *exec.Cmd) error {
 	return nil
 }
 func (m *mockExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
-	return nil, nil
+	if m.outputFunc != nil {
+		return m.outputFunc(cmd)
+	}
+	return m.output, m.outputErr
 }
 func (m *mockExecutor) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	m.commands = append(m.commands, strings.Join(cmd.Args, " "))
 	return m.combinedOutput, m.combinedOutputErr
 }
 func (m *mockExecutor) IsRoot() bool  { return m.isRoot }
@@ -40,6 +54,7 @@ type mockFileSystem struct {
 	readFileData  []byte
 	readFileErr   error
 	openReader    io.ReadCloser
+	openFiles     map[string]string
 	openErr       error
 	homeDir       string
 	homeDirErr    error
@@ -67,6 +82,10 @@ func (m *mockFileSystem) Open(name string) (*os.File, error) {
 	r, w, _ := os.Pipe()
 	go func() {
 		defer w.Close()
+		if content, ok := m.openFiles[name]; ok {
+			io.WriteString(w, content)
+			return
+		}
 		if m.openReader != nil {
 			io.Copy(w, m.openReader)
 		}
@@ -113,7 +132,9 @@ func (m mockFileInfo) Sys() any           { return nil }
 // --- Test Helper ---
 
 func setupService(exec *mockExecutor, fs *mockFileSystem) *Service {
-	return NewService(exec, fs)
+	s := NewService(exec, fs)
+	s.facts = func() system.Facts { return system.Facts{} }
+	return s
 }
 
 // --- Tests ---
@@ -188,7 +209,7 @@ func TestService_LoadPackagesCmd(t *testing.T) {
 		}
 		service := setupService(&mockExecutor{}, mockFS)
 
-		msg := service.loadPackagesCmd("/fake", "packages.txt")()
+		msg := service.loadPackagesCmd("/fake", Profile{Path: "packages.txt"})()
 
 		resultMsg, ok := msg.(packagesLoadedMsg)
 		if !ok {
@@ -211,7 +232,7 @@ func TestService_LoadPackagesCmd(t *testing.T) {
 		}
 		service := setupService(&mockExecutor{}, mockFS)
 
-		msg := service.loadPackagesCmd("/fake", "packages.txt")()
+		msg := service.loadPackagesCmd("/fake", Profile{Path: "packages.txt"})()
 
 		if _, ok := msg.(errMsg); !ok {
 			t.Fatalf("Expected msg of type errMsg, but got %T", msg)
@@ -219,6 +240,94 @@ func TestService_LoadPackagesCmd(t *testing.T) {
 	})
 }
 
+func TestService_LoadPackageLists(t *testing.T) {
+	t.Run("it merges lists in order without duplicates", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/common.txt": "git\nzsh\n",
+			"/dots/dev.txt":    "go\ngit\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackageLists("/dots", []PackageList{{Path: "common.txt"}, {Path: "dev.txt"}}, Machine{})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(packages, []string{"git", "zsh", "go"}) {
+			t.Errorf("Expected [git zsh go], but got %v", packages)
+		}
+	})
+}
+
+func TestService_LoadProfilePackages(t *testing.T) {
+	t.Run("it adds the tools after the package lists", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/dev.txt": "git\ncargo\npip:black\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+		profile := Profile{
+			Path: "dev.txt",
+			Tools: Tools{
+				Cargo: []string{"ripgrep"},
+				Go:    []string{"golang.org/x/tools/gopls"},
+				Npm:   []string{"typescript"},
+				Pipx:  []string{"black"},
+			},
+		}
+
+		packages, err := service.LoadProfilePackages("/dots", profile)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{"git", "cargo", "pip:black", "cargo:ripgrep", "go:golang.org/x/tools/gopls", "npm:typescript"}
+		if !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+		if got := CountTools(packages); got != 4 {
+			t.Errorf("Expected 4 tools, but got %d", got)
+		}
+	})
+}
+
+func TestService_InstalledTools(t *testing.T) {
+	t.Run("it reports the tools that are already installed", func(t *testing.T) {
+		bin := t.TempDir()
+		if err := os.WriteFile(filepath.Join(bin, "cargo"), nil, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", bin)
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte("ripgrep v14.1.0:\n    rg\n"), nil
+		}}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		got := service.InstalledTools([]string{"git", "ripgrep", "cargo:ripgrep", "cargo:bat", "npm:typescript"})
+
+		if want := map[string]bool{"cargo:ripgrep": true}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, but got %v", want, got)
+		}
+	})
+}
+
+func TestService_ViewOutputCmd(t *testing.T) {
+	t.Run("it opens the log of an entry with slashes in its name", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		service := setupService(&mockExecutor{}, &mockFileSystem{})
+
+		msg := service.viewOutputCmd("go:golang.org/x/tools/gopls", "build failed")()
+
+		if closed, ok := msg.(logClosedMsg); ok {
+			t.Fatalf("Expected the pager to open, but got %v", closed.err)
+		}
+		logs, _ := filepath.Glob(filepath.Join(tmp, "bas-go_golang.org_x_tools_gopls-*.log"))
+		if len(logs) != 1 {
+			t.Errorf("Expected one log file, but got %v", logs)
+		}
+	})
+}
+
 func TestService_StowCmd(t *testing.T) {
 	t.Run("it runs stow successfully", func(t *testing.T) {
 		mockFS := &mockFileSystem{homeDir: "/home/user"}
@@ -256,10 +365,13 @@ func TestService_StowCmd(t *testing.T) {
 }
 
 func TestService_InstallPackageCmd(t *testing.T) {
-	t.Run("it creates a runner script and returns an exec command", func(t *testing.T) {
+	t.Run("it installs with the selected package manager", func(t *testing.T) {
 		mockExec := &mockExecutor{}
 		mockFS := &mockFileSystem{}
 		service := setupService(mockExec, mockFS)
+		if err := service.UsePackageManager("yay", pkgmgr.Config{}); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
 
 		cmdFunc := service.installPackageCmd("pkg1")
 		if cmdFunc == nil {
@@ -278,3 +390,89 @@ func TestService_InstallPackageCmd(t *testing.T) {
 		}
 	})
 }
+
+func TestService_BatchInstallCommand(t *testing.T) {
+	service := setupService(&mockExecutor{}, &mockFileSystem{})
+	if err := service.UsePackageManager("yay", pkgmgr.Config{}); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{SourceRepo, "sudo pacman -S --needed --noconfirm git zsh"},
+		{SourceAUR, "yay -S --needed --noconfirm git zsh"},
+	}
+
+	for _, tt := range tests {
+		t.Run("it installs "+tt.source+" packages in one transaction", func(t *testing.T) {
+			cmd, err := service.BatchInstallCommand(InstallBatch{
+				Source:   tt.source,
+				Packages: []string{"git", "zsh"},
+			})
+
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if got := strings.Join(cmd.Args, " "); got != tt.want {
+				t.Errorf("Expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("it strips the aur: prefix", func(t *testing.T) {
+		cmd, err := service.BatchInstallCommand(InstallBatch{
+			Source:   SourceAUR,
+			Packages: []string{"aur:spotify"},
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if got := strings.Join(cmd.Args, " "); got != "yay -S --needed --noconfirm spotify" {
+			t.Errorf("Expected the prefix to be stripped, but got %q", got)
+		}
+	})
+
+	t.Run("it rejects unknown sources", func(t *testing.T) {
+		if _, err := service.BatchInstallCommand(InstallBatch{Source: "nope"}); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("it needs an AUR helper for AUR packages", func(t *testing.T) {
+		service := setupService(&mockExecutor{}, &mockFileSystem{})
+		if err := service.UsePackageManager("pacman", pkgmgr.Config{}); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		_, err := service.BatchInstallCommand(InstallBatch{Source: SourceAUR, Packages: []string{"spotify"}})
+		if err == nil || !strings.Contains(err.Error(), "AUR") {
+			t.Errorf("Expected an AUR helper error, but got %v", err)
+		}
+	})
+}
+
+func TestUnbatchedPackages(t *testing.T) {
+	batches := []InstallBatch{{Source: SourceRepo, Packages: []string{"git"}}}
+
+	got := UnbatchedPackages([]string{"git", "zsh", "fzf"}, batches)
+
+	if !reflect.DeepEqual(got, []string{"zsh", "fzf"}) {
+		t.Errorf("Expected [zsh fzf], but got %v", got)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	t.Run("it keeps only the end of the output", func(t *testing.T) {
+		tail := &outputTail{limit: 8}
+
+		fmt.Fprint(tail, "error: ")
+		fmt.Fprint(tail, "mirror timeout")
+
+		if got := tail.String(); got != " timeout" {
+			t.Errorf("Expected %q, but got %q", " timeout", got)
+		}
+	})
+}
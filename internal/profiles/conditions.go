@@ -0,0 +1,163 @@
+package profiles
+
+import (
+	"archsetup/internal/system"
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// whenMarker starts a condition in a trailing comment, e.g.
+// "steam  # @when role=gaming gpu=nvidia,amd".
+const whenMarker = "@when"
+
+// conditionKeys are the names a condition can test.
+var conditionKeys = []string{"role", "gpu", "cpu", "chassis", "virt", "boot", "init", "kernel"}
+
+// Machine is what conditions are evaluated against: the roles of the
+// selected profile and the detected hardware.
+type Machine struct {
+	Roles []string
+	Facts system.Facts
+}
+
+// clause tests one key. It holds when the machine has any of the values, or
+// none of them when negated.
+type clause struct {
+	key    string
+	values []string
+	negate bool
+}
+
+// Condition is a set of clauses that must all hold. The zero Condition
+// always holds.
+type Condition []clause
+
+// ParseCondition parses space separated "key=value" clauses. A clause can
+// list alternatives ("gpu=nvidia,amd") or be negated ("chassis!=laptop").
+func ParseCondition(s string) (Condition, error) {
+	var cond Condition
+	for _, field := range strings.Fields(s) {
+		key, value, negate := field, "", false
+		if k, v, ok := strings.Cut(field, "!="); ok {
+			key, value, negate = k, v, true
+		} else if k, v, ok := strings.Cut(field, "="); ok {
+			key, value = k, v
+		}
+
+		c, err := newClause(key, value, negate)
+		if err != nil {
+			return nil, err
+		}
+		cond = append(cond, c)
+	}
+
+	return cond, nil
+}
+
+// conditionFromTable builds a condition from a [profiles.when] table. Keys
+// are sorted so errors are reported in a stable order.
+func conditionFromTable(when map[string]string) (Condition, error) {
+	keys := make([]string, 0, len(when))
+	for key := range when {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var cond Condition
+	for _, key := range keys {
+		c, err := newClause(key, when[key], false)
+		if err != nil {
+			return nil, err
+		}
+		cond = append(cond, c)
+	}
+
+	return cond, nil
+}
+
+func newClause(key, value string, negate bool) (clause, error) {
+	if !slices.Contains(conditionKeys, key) {
+		return clause{}, fmt.Errorf(
+			"unknown condition %q (expected one of %s)",
+			key,
+			strings.Join(conditionKeys, ", "),
+		)
+	}
+
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return clause{}, fmt.Errorf("condition %q needs a value", key)
+	}
+
+	return clause{key: key, values: values, negate: negate}, nil
+}
+
+// Matches reports whether every clause holds on the machine.
+func (c Condition) Matches(m Machine) bool {
+	for _, cl := range c {
+		have := m.values(cl.key)
+		found := slices.ContainsFunc(cl.values, func(v string) bool {
+			return slices.Contains(have, v)
+		})
+		if found == cl.negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Machine) values(key string) []string {
+	var values []string
+	switch key {
+	case "role":
+		values = m.Roles
+	case "gpu":
+		values = m.Facts.GPUVendors
+	case "cpu":
+		values = []string{m.Facts.CPUVendor}
+	case "chassis":
+		values = []string{m.Facts.Chassis}
+	case "virt":
+		values = []string{cmp.Or(m.Facts.Virtualization, "none")}
+	case "boot":
+		values = []string{m.Facts.BootMode}
+	case "init":
+		values = []string{m.Facts.Init}
+	case "kernel":
+		values = m.Facts.Kernels
+	}
+
+	lower := make([]string, 0, len(values))
+	for _, v := range values {
+		lower = append(lower, strings.ToLower(strings.TrimSpace(v)))
+	}
+	return lower
+}
+
+// splitCondition separates an entry from its trailing comment and parses
+// the condition in it: a comment that starts with @when. Other comments,
+// and full-line comments whatever they say, are dropped.
+func splitCondition(line string) (string, Condition, error) {
+	entry, comment, found := strings.Cut(line, "#")
+	entry = strings.TrimSpace(entry)
+	if !found || entry == "" {
+		return entry, nil, nil
+	}
+
+	expr, found := strings.CutPrefix(strings.TrimSpace(comment), whenMarker)
+	if !found {
+		return entry, nil, nil
+	}
+
+	cond, err := ParseCondition(expr)
+	return entry, cond, err
+}
@@ -0,0 +1,206 @@
+package profiles
+
+import (
+	"archsetup/internal/system"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var gamingLaptop = Machine{
+	Roles: []string{"gaming", "dev"},
+	Facts: system.Facts{
+		CPUVendor:  "amd",
+		GPUVendors: []string{"amd", "nvidia"},
+		Chassis:    system.ChassisLaptop,
+		BootMode:   system.BootUEFI,
+		Init:       "systemd",
+		Kernels:    []string{"linux", "linux-lts"},
+	},
+}
+
+func TestCondition_Matches(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"role=gaming", true},
+		{"role=streaming", false},
+		{"gpu=nvidia", true},
+		{"gpu=intel,nvidia", true},
+		{"cpu=intel", false},
+		{"chassis=laptop role=dev", true},
+		{"chassis=laptop role=streaming", false},
+		{"chassis!=laptop", false},
+		{"gpu!=intel", true},
+		{"gpu=NVIDIA", true},
+		{"virt=none boot=uefi", true},
+		{"kernel=linux-lts", true},
+		{"init=openrc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run("it evaluates "+tt.expr, func(t *testing.T) {
+			cond, err := ParseCondition(tt.expr)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if got := cond.Matches(gamingLaptop); got != tt.want {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	t.Run("it rejects unknown keys", func(t *testing.T) {
+		_, err := ParseCondition("ram=32g")
+		if err == nil || !strings.Contains(err.Error(), `unknown condition "ram"`) {
+			t.Errorf("Expected an unknown condition error, but got %v", err)
+		}
+	})
+
+	t.Run("it rejects clauses without a value", func(t *testing.T) {
+		if _, err := ParseCondition("role="); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestService_LoadPackagesConditions(t *testing.T) {
+	t.Run("it skips lines whose condition does not hold", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/list.txt": "git # always\n" +
+				"steam  # @when role=gaming\n" +
+				"obs-studio # @when role=streaming\n" +
+				"nvtop # @when gpu=nvidia\n" +
+				"tlp # @when chassis=desktop\n" +
+				"@include extra.txt # @when cpu=intel\n",
+			"/dots/extra.txt": "intel-ucode\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackages("/dots", "list.txt", gamingLaptop)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{"git", "steam", "nvtop"}
+		if !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+	})
+
+	t.Run("it reports malformed conditions with their line", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/list.txt": "git\nsteam # @when mood=happy\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		_, err := service.LoadPackages("/dots", "list.txt", gamingLaptop)
+
+		if err == nil || !strings.Contains(err.Error(), "list.txt:2:") {
+			t.Errorf("Expected an error for line 2, but got %v", err)
+		}
+	})
+
+	t.Run("it treats full-line comments as comments", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/list.txt": "# use @when to gate laptop-only packages\n" +
+				"# @when chassis=laptop\n" +
+				"git\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackages("/dots", "list.txt", gamingLaptop)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(packages, []string{"git"}) {
+			t.Errorf("Expected [git], but got %v", packages)
+		}
+	})
+
+	t.Run("it only reads conditions at the start of a trailing comment", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/list.txt": "git # no @when here, always installed\n" +
+				"obs-studio # @when role=streaming\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackages("/dots", "list.txt", gamingLaptop)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(packages, []string{"git"}) {
+			t.Errorf("Expected [git], but got %v", packages)
+		}
+	})
+}
+
+func TestConfig_ProfilesForConditions(t *testing.T) {
+	cfg := Config{Profiles: []Profile{
+		{
+			Name:     "Gaming",
+			Roles:    []string{"gaming"},
+			StowDirs: []string{"git", "steam # @when role=gaming", "tlp # @when chassis=laptop"},
+		},
+		{Name: "NVIDIA only", When: map[string]string{"gpu": "nvidia"}},
+		{Name: "AMD or Intel", When: map[string]string{"gpu": "amd,intel"}},
+	}}
+	facts := system.Facts{GPUVendors: []string{"amd"}, Chassis: system.ChassisDesktop}
+
+	got := cfg.ProfilesFor(system.OSInfo{Family: "linux", Distro: "arch"}, facts)
+
+	var names []string
+	for _, p := range got {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Gaming", "AMD or Intel"}) {
+		t.Fatalf("Expected [Gaming, AMD or Intel], but got %v", names)
+	}
+	if !reflect.DeepEqual(got[0].StowDirs, []string{"git", "steam"}) {
+		t.Errorf("Expected stow dirs [git steam], but got %v", got[0].StowDirs)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{Profiles: []Profile{
+		{Name: "Broken", StowDirs: []string{"steam # @when gpu"}},
+	}}
+
+	err := cfg.validate()
+
+	if err == nil || !strings.Contains(err.Error(), `profile "Broken": stow_dirs`) {
+		t.Errorf("Expected a stow_dirs error naming the profile, but got %v", err)
+	}
+}
+
+func TestPostInstallCommand_Render(t *testing.T) {
+	t.Run("it expands the machine into the command", func(t *testing.T) {
+		cmd := PostInstallCommand{
+			Command:    `./bootstrap.sh --gpu {{ join .Facts.GPUVendors "," }} --roles {{ join .Roles "," }}`,
+			WorkingDir: "ansible",
+		}
+
+		got, err := cmd.Render(gamingLaptop)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if want := "./bootstrap.sh --gpu amd,nvidia --roles gaming,dev"; got.Command != want {
+			t.Errorf("Expected %q, but got %q", want, got.Command)
+		}
+	})
+
+	t.Run("it reports unknown fields", func(t *testing.T) {
+		cmd := PostInstallCommand{Command: "echo {{ .Facts.Colour }}"}
+
+		if _, err := cmd.Render(gamingLaptop); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
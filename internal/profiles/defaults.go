@@ -0,0 +1,255 @@
+package profiles
+
+import (
+	"archsetup/internal/system"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultTypes are the value types `defaults write` is given.
+var defaultTypes = []string{"bool", "int", "float", "string"}
+
+// restartApps are the apps that only pick up changes to their domain after a
+// restart.
+var restartApps = map[string]string{
+	"com.apple.dock":           "Dock",
+	"com.apple.finder":         "Finder",
+	"com.apple.systemuiserver": "SystemUIServer",
+}
+
+// MacDefault is a macOS user default, written with `defaults write`. Type
+// can be left out and is then taken from the TOML value.
+type MacDefault struct {
+	Domain string `toml:"domain"`
+	Key    string `toml:"key"`
+	Type   string `toml:"type"`
+	Value  any    `toml:"value"`
+}
+
+// DefaultChange is a default together with its current value.
+type DefaultChange struct {
+	MacDefault
+	// Current is what `defaults read` prints, empty when the key is unset.
+	Current string
+}
+
+type defaultsReadMsg struct {
+	changes []DefaultChange
+}
+
+type defaultsAppliedMsg struct {
+	err error
+}
+
+// MacDefaults returns the defaults the profile sets on the OS: none outside
+// macOS.
+func (p Profile) MacDefaults(info system.OSInfo) []MacDefault {
+	if info.Family != "darwin" {
+		return nil
+	}
+
+	return p.Defaults
+}
+
+// valueType returns the declared type, or the one the TOML value has.
+func (d MacDefault) valueType() string {
+	if d.Type != "" {
+		return d.Type
+	}
+
+	switch d.Value.(type) {
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	}
+	return ""
+}
+
+// validate reports a missing domain or key, or a value that does not fit
+// the type.
+func (d MacDefault) validate() error {
+	if d.Domain == "" || d.Key == "" {
+		return errors.New("defaults need a domain and a key")
+	}
+	if !slices.Contains(defaultTypes, d.valueType()) {
+		return fmt.Errorf(
+			"default %s %s: unsupported type %q (expected one of %s)",
+			d.Domain,
+			d.Key,
+			d.valueType(),
+			strings.Join(defaultTypes, ", "),
+		)
+	}
+	if _, err := d.arg(); err != nil {
+		return fmt.Errorf("default %s %s: %w", d.Domain, d.Key, err)
+	}
+
+	return nil
+}
+
+// arg formats the value for `defaults write`.
+func (d MacDefault) arg() (string, error) {
+	switch v := d.Value.(type) {
+	case bool:
+		if d.valueType() == "bool" {
+			return strconv.FormatBool(v), nil
+		}
+	case int64:
+		switch d.valueType() {
+		case "int", "float":
+			return strconv.FormatInt(v, 10), nil
+		}
+	case float64:
+		if d.valueType() == "float" {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case string:
+		if d.valueType() == "string" {
+			return v, nil
+		}
+	}
+
+	return "", fmt.Errorf("value %v is not a %s", d.Value, d.valueType())
+}
+
+// Desired is the value as `defaults read` prints it once written: booleans
+// read back as 1 and 0.
+func (c DefaultChange) Desired() string {
+	arg, _ := c.arg()
+	switch arg {
+	case "true":
+		if c.valueType() == "bool" {
+			return "1"
+		}
+	case "false":
+		if c.valueType() == "bool" {
+			return "0"
+		}
+	}
+
+	return arg
+}
+
+// Changed reports whether writing the default changes anything.
+func (c DefaultChange) Changed() bool {
+	if c.valueType() == "float" {
+		current, err1 := strconv.ParseFloat(c.Current, 64)
+		desired, err2 := strconv.ParseFloat(c.Desired(), 64)
+		if err1 == nil && err2 == nil {
+			return current != desired
+		}
+	}
+
+	return c.Current != c.Desired()
+}
+
+// ReadDefaults reads the current value of every default. Unset keys have an
+// empty current value.
+func (s *Service) ReadDefaults(defaults []MacDefault) []DefaultChange {
+	changes := make([]DefaultChange, 0, len(defaults))
+	for _, d := range defaults {
+		output, err := s.exec.Output(exec.Command("defaults", "read", d.Domain, d.Key))
+		current := strings.TrimSpace(string(output))
+		if err != nil {
+			current = ""
+		}
+		changes = append(changes, DefaultChange{MacDefault: d, Current: current})
+	}
+
+	return changes
+}
+
+// ApplyDefaults writes the defaults that differ from their current value and
+// restarts the apps whose domains changed.
+func (s *Service) ApplyDefaults(changes []DefaultChange) error {
+	var restart []string
+	for _, c := range changes {
+		if !c.Changed() {
+			continue
+		}
+
+		arg, err := c.arg()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command("defaults", "write", c.Domain, c.Key, "-"+c.valueType(), arg)
+		if output, err := s.exec.CombinedOutput(cmd); err != nil {
+			return fmt.Errorf("defaults write %s %s failed: %w\n%s", c.Domain, c.Key, err, output)
+		}
+
+		if app, ok := restartApps[strings.ToLower(c.Domain)]; ok && !slices.Contains(restart, app) {
+			restart = append(restart, app)
+		}
+	}
+
+	for _, app := range restart {
+		// killall fails when the app is not running, which is fine: it reads
+		// the new settings when it starts.
+		if err := s.exec.Run(exec.Command("killall", app)); err != nil {
+			log.Printf("profiles: could not restart %s: %v", app, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) readDefaultsCmd(defaults []MacDefault) tea.Cmd {
+	return func() tea.Msg {
+		return defaultsReadMsg{changes: s.ReadDefaults(defaults)}
+	}
+}
+
+func (s *Service) applyDefaultsCmd(changes []DefaultChange) tea.Cmd {
+	return func() tea.Msg {
+		return defaultsAppliedMsg{err: s.ApplyDefaults(changes)}
+	}
+}
+
+// FormatDefaults lists the defaults with their current and desired values.
+func FormatDefaults(changes []DefaultChange) string {
+	var b strings.Builder
+	for _, c := range changes {
+		if !c.Changed() {
+			fmt.Fprintf(&b, "✓ %s %s: %s\n", c.Domain, c.Key, c.Current)
+			continue
+		}
+
+		current := c.Current
+		if current == "" {
+			current = "(unset)"
+		}
+		fmt.Fprintf(&b, "  %s %s: %s → %s\n", c.Domain, c.Key, current, c.Desired())
+	}
+
+	return b.String()
+}
+
+// mergeDefaults combines the defaults of a parent and a child; the child's
+// value wins for the same domain and key.
+func mergeDefaults(parent, child []MacDefault) []MacDefault {
+	var merged []MacDefault
+	for _, d := range slices.Concat(parent, child) {
+		i := slices.IndexFunc(merged, func(m MacDefault) bool {
+			return m.Domain == d.Domain && m.Key == d.Key
+		})
+		if i >= 0 {
+			merged[i] = d
+		} else {
+			merged = append(merged, d)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,183 @@
+package profiles
+
+import (
+	"archsetup/internal/system"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMacDefaultValidate(t *testing.T) {
+	t.Run("it takes the type from the value", func(t *testing.T) {
+		defaults := []MacDefault{
+			{Domain: "com.apple.dock", Key: "autohide", Value: true},
+			{Domain: "com.apple.dock", Key: "tilesize", Value: int64(48)},
+			{Domain: "com.apple.dock", Key: "autohide-delay", Value: 0.2},
+			{Domain: "com.apple.finder", Key: "FXPreferredViewStyle", Value: "Nlsv"},
+			{Domain: "com.apple.dock", Key: "autohide-time-modifier", Type: "float", Value: int64(1)},
+		}
+
+		for _, d := range defaults {
+			if err := d.validate(); err != nil {
+				t.Errorf("Expected %s %s to be valid, but got %v", d.Domain, d.Key, err)
+			}
+		}
+	})
+
+	t.Run("it rejects a value that does not fit the type", func(t *testing.T) {
+		d := MacDefault{Domain: "com.apple.dock", Key: "autohide", Type: "bool", Value: "yes"}
+
+		if err := d.validate(); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("it needs a domain and a key", func(t *testing.T) {
+		d := MacDefault{Key: "autohide", Value: true}
+
+		if err := d.validate(); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestDefaultChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  DefaultChange
+		desired string
+		changed bool
+	}{
+		{
+			name:    "booleans read back as 1 and 0",
+			change:  DefaultChange{MacDefault: MacDefault{Value: true}, Current: "1"},
+			desired: "1",
+			changed: false,
+		},
+		{
+			name:    "unset keys change",
+			change:  DefaultChange{MacDefault: MacDefault{Value: false}},
+			desired: "0",
+			changed: true,
+		},
+		{
+			name:    "floats compare by value",
+			change:  DefaultChange{MacDefault: MacDefault{Value: 0.5}, Current: "0.50"},
+			desired: "0.5",
+			changed: false,
+		},
+		{
+			name:    "strings compare as they are",
+			change:  DefaultChange{MacDefault: MacDefault{Value: "Nlsv"}, Current: "icnv"},
+			desired: "Nlsv",
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.change.Desired(); got != tt.desired {
+				t.Errorf("Expected desired value %q, but got %q", tt.desired, got)
+			}
+			if got := tt.change.Changed(); got != tt.changed {
+				t.Errorf("Expected changed %v, but got %v", tt.changed, got)
+			}
+		})
+	}
+}
+
+func TestReadDefaults(t *testing.T) {
+	t.Run("it reads current values and treats errors as unset", func(t *testing.T) {
+		exec := &mockExecutor{
+			outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				if cmd.Args[3] == "autohide" {
+					return []byte("1\n"), nil
+				}
+				return nil, errors.New("does not exist")
+			},
+		}
+		service := setupService(exec, &mockFileSystem{})
+
+		changes := service.ReadDefaults([]MacDefault{
+			{Domain: "com.apple.dock", Key: "autohide", Value: true},
+			{Domain: "com.apple.dock", Key: "tilesize", Value: int64(48)},
+		})
+
+		if len(changes) != 2 || changes[0].Current != "1" || changes[1].Current != "" {
+			t.Errorf("Expected current values 1 and unset, but got %+v", changes)
+		}
+	})
+}
+
+func TestApplyDefaults(t *testing.T) {
+	t.Run("it writes changed defaults and restarts their apps", func(t *testing.T) {
+		exec := &mockExecutor{}
+		service := setupService(exec, &mockFileSystem{})
+
+		err := service.ApplyDefaults([]DefaultChange{
+			{MacDefault: MacDefault{Domain: "com.apple.dock", Key: "autohide", Value: true}, Current: "1"},
+			{MacDefault: MacDefault{Domain: "com.apple.dock", Key: "tilesize", Value: int64(48)}, Current: "64"},
+			{MacDefault: MacDefault{Domain: "NSGlobalDomain", Key: "AppleShowAllExtensions", Value: true}},
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{
+			"defaults write com.apple.dock tilesize -int 48",
+			"defaults write NSGlobalDomain AppleShowAllExtensions -bool true",
+			"killall Dock",
+		}
+		if !reflect.DeepEqual(exec.commands, want) {
+			t.Errorf("Expected commands %v, but got %v", want, exec.commands)
+		}
+	})
+
+	t.Run("it stops at the first failed write", func(t *testing.T) {
+		exec := &mockExecutor{combinedOutputErr: errors.New("exit status 1")}
+		service := setupService(exec, &mockFileSystem{})
+
+		err := service.ApplyDefaults([]DefaultChange{
+			{MacDefault: MacDefault{Domain: "com.apple.dock", Key: "autohide", Value: true}},
+		})
+
+		if err == nil || !strings.Contains(err.Error(), "com.apple.dock autohide") {
+			t.Errorf("Expected an error naming the default, but got %v", err)
+		}
+	})
+}
+
+func TestMacDefaults(t *testing.T) {
+	p := Profile{Defaults: []MacDefault{{Domain: "com.apple.dock", Key: "autohide", Value: true}}}
+
+	if got := p.MacDefaults(system.OSInfo{Family: "linux"}); got != nil {
+		t.Errorf("Expected no defaults on Linux, but got %v", got)
+	}
+	if got := p.MacDefaults(system.OSInfo{Family: "darwin"}); len(got) != 1 {
+		t.Errorf("Expected the defaults on macOS, but got %v", got)
+	}
+}
+
+func TestMergeDefaults(t *testing.T) {
+	parent := []MacDefault{
+		{Domain: "com.apple.dock", Key: "autohide", Value: true},
+		{Domain: "com.apple.dock", Key: "tilesize", Value: int64(48)},
+	}
+	child := []MacDefault{
+		{Domain: "com.apple.dock", Key: "tilesize", Value: int64(36)},
+		{Domain: "com.apple.finder", Key: "ShowPathbar", Value: true},
+	}
+
+	got := mergeDefaults(parent, child)
+
+	want := []MacDefault{
+		{Domain: "com.apple.dock", Key: "autohide", Value: true},
+		{Domain: "com.apple.dock", Key: "tilesize", Value: int64(36)},
+		{Domain: "com.apple.finder", Key: "ShowPathbar", Value: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+}
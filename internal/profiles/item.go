@@ -9,3 +9,10 @@ func (p profileItem) Description() string { return p.Profile.Description }
 func (p profileItem) FilterValue() string { return p.Profile.Name }
 
 func (p profileItem) IsEnabled() bool { return true }
+
+type packageItem struct {
+	name   string
+	source string
+}
+
+func (p packageItem) FilterValue() string { return p.name }
@@ -1,7 +1,10 @@
 package profiles
 
 import (
+	"archsetup/internal/answers"
+	"archsetup/internal/events"
 	"archsetup/internal/navigator"
+	"archsetup/internal/state"
 	"archsetup/internal/styles"
 	"archsetup/internal/system"
 	"archsetup/internal/types"
@@ -11,6 +14,7 @@ import (
 	"io"
 	"log"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 
@@ -29,12 +33,20 @@ const (
 	selectOptionPhase
 	loadingPackagesPhase
 	confirmationPhase
-	checkingYayPhase
-	installingYayPhase
+	planningPhase
+	planPreviewPhase
+	checkingPkgMgrPhase
+	settingUpPkgMgrPhase
 	installingPackagesPhase
+	retryFailedPhase
+	readingDefaultsPhase
+	defaultsPhase
+	applyingDefaultsPhase
 	postInstallConfirmationPhase
 	postInstallRunningPhase
 	installCompletePhase
+	findingPrunePhase
+	prunePhase
 	errorPhase
 )
 
@@ -42,24 +54,65 @@ type DotfilesPathUpdatedMsg struct {
 	Path string
 }
 
+// ResumeMsg continues an unfinished run of Profile. Packages holds the
+// outcome of every package that run already got to.
+type ResumeMsg struct {
+	Profile    string
+	Packages   map[string]string
+	Deselected []string
+}
+
 type tickMsg struct{}
 
 type Model struct {
-	dotfilesPath        string
-	list                list.Model
-	viewport            viewport.Model
-	selectedProfile     profileItem
-	packagesToInstall   []string
-	packagesSucceeded   []string
-	packagesFailed      []string
-	currentPackageIndex int
-	nav                 navigator.Navigator[phase]
-	keys                types.KeyMap
-	spinner             spinner.Model
-	width               int
-	height              int
-	service             *Service
-	err                 error
+	dotfilesPath      string
+	list              list.Model
+	viewport          viewport.Model
+	selectedProfile   profileItem
+	toInstall         []string
+	packageSets       PackageSets
+	profilePackages   []string
+	keep              []string
+	packageList       list.Model
+	deselected        map[string]bool
+	packagesSucceeded []string
+	packagesFailed    []string
+	nav               navigator.Navigator[phase]
+	keys              types.KeyMap
+	spinner           spinner.Model
+	width             int
+	height            int
+	service           *Service
+	store             *state.Store
+	err               error
+
+	// answers is set for unattended runs; every confirmation is taken
+	// automatically.
+	answers *answers.Answers
+
+	// resume holds a previous run of the same profile whose package
+	// outcomes are reused instead of installing those packages again.
+	resume *ResumeMsg
+
+	// installBatches and installQueue hold the work left: batches run
+	// first, packages of failed batches are then retried one by one.
+	installBatches []InstallBatch
+	installQueue   []string
+	installing     string
+
+	// prune mode state
+	pruneList       list.Model
+	pruneDeselected map[string]bool
+	pruneSummary    string
+
+	// failures keeps what went wrong for each failed package, for the
+	// retry screen.
+	failures    map[string]packageFailure
+	retryCursor int
+
+	// defaultChanges are the macOS defaults of the profile with their
+	// current values.
+	defaultChanges []DefaultChange
 
 	// install process state
 	execCmd *exec.Cmd
@@ -89,7 +142,11 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	fmt.Fprintf(w, "%s\n%s", title, desc)
 }
 
-func New(keys types.KeyMap, service *Service) tea.Model {
+func New(
+	keys types.KeyMap,
+	service *Service,
+	store *state.Store,
+) tea.Model {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = styles.SpinnerStyle
@@ -113,12 +170,15 @@ func New(keys types.KeyMap, service *Service) tea.Model {
 	vp := viewport.New(0, 0)
 
 	return &Model{
-		keys:     keys,
-		nav:      navigator.New(checkingConfigurationPhase),
-		spinner:  s,
-		list:     profileList,
-		viewport: vp,
-		service:  service,
+		keys:        keys,
+		nav:         navigator.New(checkingConfigurationPhase),
+		spinner:     s,
+		list:        profileList,
+		packageList: list.New([]list.Item{}, packageDelegate{}, 0, 0),
+		pruneList:   list.New([]list.Item{}, packageDelegate{}, 0, 0),
+		viewport:    vp,
+		service:     service,
+		store:       store,
 	}
 }
 
@@ -199,12 +259,21 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleWindowSizeMsg(msg)
 	case DotfilesPathUpdatedMsg:
 		return m.handleDotfilesPathUpdatedMsg(msg)
+	case answers.LoadedMsg:
+		m.answers = &msg.Answers
+		return m, nil
+	case answers.ClearedMsg:
+		m.answers = nil
+		return m, nil
+	case ResumeMsg:
+		m.resume = &msg
+		return m, nil
 	case errMsg:
 		return m.handleErrMsg(msg)
-	case yayCheckResultMsg:
-		return m.handleYayCheckResult(msg)
-	case yayInstallResultMsg:
-		return m.handleYayInstallResult(msg)
+	case pkgMgrCheckResultMsg:
+		return m.handlePkgMgrCheckResult(msg)
+	case pkgMgrSetupResultMsg:
+		return m.handlePkgMgrSetupResult(msg)
 
 	// Messages that trigger the installation process.
 	case startStreamingCmdMsg:
@@ -215,8 +284,21 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleInstallLogMsg(msg)
 	case installationFinishedMsg:
 		return m.handleInstallationFinishedMsg(msg)
+	case batchInstallResultMsg:
+		return m.handleBatchInstallResult(msg)
 	case packageInstallResultMsg:
 		return m.handlePackageInstallResult(msg)
+	case toolsCheckedMsg:
+		return m.handleToolsCheckedMsg(msg)
+	case logClosedMsg:
+		if msg.err != nil {
+			log.Printf("profiles: could not open the install log: %v", msg.err)
+		}
+		return m, nil
+	case defaultsReadMsg:
+		return m.handleDefaultsReadMsg(msg)
+	case defaultsAppliedMsg:
+		return m.handleDefaultsAppliedMsg(msg)
 	case postInstallLogMsg:
 		return m.handlePostInstallLogMsg(msg)
 	case postInstallCompleteMsg:
@@ -229,6 +311,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleProfilesNotFoundMsg(msg)
 	case packagesLoadedMsg:
 		return m.handlePackagesLoadedMsg(msg)
+	case planReadyMsg:
+		return m.handlePlanReadyMsg(msg)
+	case pruneCandidatesMsg:
+		return m.handlePruneCandidatesMsg(msg)
+	case packagesRemovedMsg:
+		return m.handlePackagesRemovedMsg(msg)
 	case stowResultMsg:
 		return m.handleStowResultMsg(msg)
 
@@ -239,13 +327,20 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// For other messages (like spinner ticks), update the relevant component.
 	switch m.nav.Current() {
-	case checkingConfigurationPhase, loadingPackagesPhase:
+	case checkingConfigurationPhase, loadingPackagesPhase, planningPhase, findingPrunePhase,
+		readingDefaultsPhase, applyingDefaultsPhase:
 		m.spinner, cmd = m.spinner.Update(msg)
 		cmds = append(cmds, cmd)
 	case selectOptionPhase:
 		m.list, cmd = m.list.Update(msg)
 		cmds = append(cmds, cmd)
-	case confirmationPhase, installingPackagesPhase:
+	case confirmationPhase:
+		m.packageList, cmd = m.packageList.Update(msg)
+		cmds = append(cmds, cmd)
+	case prunePhase:
+		m.pruneList, cmd = m.pruneList.Update(msg)
+		cmds = append(cmds, cmd)
+	case planPreviewPhase, installingPackagesPhase, defaultsPhase:
 		m.viewport, cmd = m.viewport.Update(msg)
 		cmds = append(cmds, cmd)
 	}
@@ -260,6 +355,8 @@ func (m *Model) handleWindowSizeMsg(
 	m.height = msg.Height
 	m.viewport.Width = msg.Width
 	m.viewport.Height = msg.Height - 8
+	m.packageList.SetSize(msg.Width, msg.Height-8)
+	m.pruneList.SetSize(msg.Width, msg.Height-8)
 	return m, nil
 }
 
@@ -270,6 +367,8 @@ func (m *Model) handleStartStreamingMsg(
 }
 
 func (m *Model) handleStowResultMsg(msg stowResultMsg) (tea.Model, tea.Cmd) {
+	events.Result(events.StowResult, "", msg.err)
+
 	if msg.err != nil {
 		log.Printf("stow command failed: %v", msg.err)
 		m.err = msg.err
@@ -310,6 +409,8 @@ func (m *Model) handlePostInstallLogMsg(
 func (m *Model) handlePostInstallFinishedMsg(
 	msg postInstallCompleteMsg,
 ) (tea.Model, tea.Cmd) {
+	events.Result(events.PostInstallResult, "", msg.err)
+
 	if msg.err != nil {
 		log.Printf("profiles: post-install script failed: %v", msg.err)
 		m.err = msg.err
@@ -318,8 +419,7 @@ func (m *Model) handlePostInstallFinishedMsg(
 	}
 
 	log.Printf("profiles: post-install script succeeded")
-	m.nav.Push(installCompletePhase)
-	return m, nil
+	return m, m.complete()
 }
 
 func (m *Model) handleInstallStartedMsg(
@@ -348,41 +448,166 @@ func (m *Model) handleInstallationFinishedMsg(
 	}
 
 	log.Println("install finished successfully")
-	m.nav.Push(installCompletePhase)
-	return m, nil
+	return m, m.complete()
+}
+
+func (m *Model) handleBatchInstallResult(
+	msg batchInstallResultMsg,
+) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		log.Printf("profiles: %s batch failed, retrying one by one: %v", msg.batch.Source, msg.err)
+		m.logBuf.WriteString(fmt.Sprintf(
+			"\n⚠ Installing %s packages together failed, retrying one by one\n",
+			msg.batch.Source,
+		))
+		m.installQueue = append(m.installQueue, msg.batch.Packages...)
+		return m.installNext()
+	}
+
+	for _, pkg := range msg.batch.Packages {
+		m.recordPackage(pkg, nil)
+	}
+
+	return m.installNext()
 }
 
 func (m *Model) handlePackageInstallResult(
 	msg packageInstallResultMsg,
 ) (tea.Model, tea.Cmd) {
+	m.recordPackage(msg.pkg, msg.err)
 	if msg.err != nil {
-		log.Printf("Failed to install package %s: %v", msg.pkg, msg.err)
-		m.packagesFailed = append(m.packagesFailed, msg.pkg)
-		m.logBuf.WriteString(fmt.Sprintf("\n❌ Failed to install %s\n", msg.pkg))
+		m.failures[msg.pkg] = packageFailure{err: msg.err, output: msg.output}
+	}
+
+	return m.installNext()
+}
+
+func (m *Model) recordPackage(pkg string, err error) {
+	events.Result(events.PackageResult, pkg, err)
+	m.store.Update(func(s *state.State) { s.RecordPackage(pkg, err) })
+
+	if err != nil {
+		log.Printf("Failed to install package %s: %v", pkg, err)
+		m.packagesFailed = append(m.packagesFailed, pkg)
+		m.logBuf.WriteString(fmt.Sprintf("\n❌ Failed to install %s\n", pkg))
 	} else {
-		log.Printf("Successfully installed package %s", msg.pkg)
-		m.packagesSucceeded = append(m.packagesSucceeded, msg.pkg)
-		m.logBuf.WriteString(fmt.Sprintf("\n✓ Successfully installed %s\n", msg.pkg))
+		log.Printf("Successfully installed package %s", pkg)
+		m.packagesSucceeded = append(m.packagesSucceeded, pkg)
+		m.logBuf.WriteString(fmt.Sprintf("\n✓ Successfully installed %s\n", pkg))
 	}
+}
 
-	m.currentPackageIndex++
+// installNext runs the next batch, then the next single package, and moves
+// on to the post-install step once every package has been processed.
+func (m *Model) installNext() (tea.Model, tea.Cmd) {
+	if len(m.installBatches) > 0 {
+		batch := m.installBatches[0]
+		m.installBatches = m.installBatches[1:]
+		m.installing = fmt.Sprintf("%d %s packages", len(batch.Packages), batch.Source)
+		return m, m.service.installBatchCmd(batch)
+	}
 
-	// If there are more packages, install the next one.
-	if m.currentPackageIndex < len(m.packagesToInstall) {
-		nextPackage := m.packagesToInstall[m.currentPackageIndex]
-		return m, m.service.installPackageCmd(nextPackage)
+	if len(m.installQueue) > 0 {
+		pkg := m.installQueue[0]
+		m.installQueue = m.installQueue[1:]
+		m.installing = pkg
+		return m, m.service.installPackageCmd(pkg)
 	}
 
+	m.installing = ""
 	log.Println("All packages processed.")
+	m.resume = nil
 
-	if m.selectedProfile.PostInstall == nil {
-		m.nav.Push(installCompletePhase)
+	if len(m.packagesFailed) > 0 && m.answers == nil {
+		m.retryCursor = 0
+		m.nav.Push(retryFailedPhase)
+		return m, nil
+	}
+
+	return m.finishInstall()
+}
+
+// finishInstall moves on from package installation to the macOS defaults,
+// then to the post-install step.
+func (m *Model) finishInstall() (tea.Model, tea.Cmd) {
+	if defaults := m.selectedProfile.MacDefaults(system.CurrentOSInfo()); len(defaults) > 0 {
+		m.nav.Push(readingDefaultsPhase)
+		return m, tea.Batch(m.spinner.Tick, m.service.readDefaultsCmd(defaults))
+	}
+
+	return m.startPostInstall()
+}
+
+func (m *Model) handleDefaultsReadMsg(msg defaultsReadMsg) (tea.Model, tea.Cmd) {
+	m.defaultChanges = msg.changes
+	if !slices.ContainsFunc(msg.changes, DefaultChange.Changed) {
+		log.Println("profiles: macOS defaults are already set")
+		return m.startPostInstall()
+	}
+
+	m.nav.Pop()
+	m.nav.Push(defaultsPhase)
+	m.viewport.SetContent(FormatDefaults(msg.changes))
+	m.viewport.GotoTop()
+
+	if m.answers != nil {
+		return m.applyDefaults()
+	}
+	return m, nil
+}
+
+func (m *Model) handleDefaultsKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Enter):
+		return m.applyDefaults()
+
+	case key.Matches(msg, m.keys.Back):
+		log.Println("profiles: skipping macOS defaults")
+		return m.startPostInstall()
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) applyDefaults() (tea.Model, tea.Cmd) {
+	m.nav.Push(applyingDefaultsPhase)
+	return m, tea.Batch(m.spinner.Tick, m.service.applyDefaultsCmd(m.defaultChanges))
+}
+
+func (m *Model) handleDefaultsAppliedMsg(msg defaultsAppliedMsg) (tea.Model, tea.Cmd) {
+	events.Result(events.DefaultsResult, "", msg.err)
+
+	if msg.err != nil {
+		log.Printf("profiles: applying macOS defaults failed: %v", msg.err)
+		m.err = msg.err
+		m.nav.Push(errorPhase)
 		return m, nil
 	}
 
+	log.Println("profiles: macOS defaults applied")
+	return m.startPostInstall()
+}
+
+// startPostInstall asks whether to run the post-install command, if the
+// profile has one.
+func (m *Model) startPostInstall() (tea.Model, tea.Cmd) {
+	if m.selectedProfile.PostInstall == nil {
+		return m, m.complete()
+	}
+
 	m.nav.Push(postInstallConfirmationPhase)
 	m.logBuf.Reset()
 	m.viewport.SetContent("")
+
+	if m.answers != nil {
+		if m.answers.RunPostInstall {
+			return m, m.runPostInstall()
+		}
+		return m, m.complete()
+	}
+
 	return m, nil
 }
 
@@ -394,23 +619,40 @@ func (m *Model) handleProfilesLoadedMsg(
 	info := system.CurrentOSInfo()
 	var items []list.Item
 
-	for _, p := range msg.Config.Profiles {
-		famOk := p.OsFamily == "" || p.OsFamily == info.Family
-		if !famOk {
-			continue
-		}
-
-		distOk := p.OsDistro == "" || p.OsDistro == info.Distro
-		if !distOk {
-			continue
-		}
-
+	for _, p := range msg.Config.ProfilesFor(info, m.service.Facts()) {
 		items = append(items, profileItem{Profile: p})
 	}
 
 	m.list.SetItems(items)
+	m.keep = msg.Config.Keep
 	m.nav.Reset(selectOptionPhase)
 
+	if m.resume != nil {
+		for _, item := range items {
+			p := item.(profileItem)
+			if p.Name == m.resume.Profile {
+				return m, m.selectProfile(p)
+			}
+		}
+
+		log.Printf("profiles: profile %q to resume no longer exists", m.resume.Profile)
+		m.resume = nil
+	}
+
+	if m.answers != nil {
+		for _, item := range items {
+			p := item.(profileItem)
+			if strings.EqualFold(p.Name, m.answers.Profile) {
+				return m, m.selectProfile(p)
+			}
+		}
+
+		return m.handleErrMsg(errMsg{fmt.Errorf(
+			"profile %q from the answer file is not available on this system",
+			m.answers.Profile,
+		)})
+	}
+
 	return m, nil
 }
 
@@ -432,12 +674,21 @@ func (m *Model) handleProfilesNotFoundMsg(
 func (m *Model) handlePackagesLoadedMsg(
 	msg packagesLoadedMsg,
 ) (tea.Model, tea.Cmd) {
-	m.packagesToInstall = msg.packages
-	content := "The following packages will be installed:\n\n" +
-		strings.Join(m.packagesToInstall, "\n")
-	m.viewport.SetContent(content)
+	m.profilePackages = msg.packages
+	m.packageSets = msg.sets
+	m.deselected = make(map[string]bool)
+	if m.resume != nil {
+		for _, pkg := range m.resume.Deselected {
+			m.deselected[pkg] = true
+		}
+	}
+	m.packageList = m.newPackageList()
 	m.nav.Push(confirmationPhase)
 
+	if m.answers != nil || m.resume != nil {
+		return m, m.confirmInstall()
+	}
+
 	return m, nil
 }
 
@@ -447,6 +698,14 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleSelectOptionKeys(msg)
 	case confirmationPhase:
 		return m.handleConfirmationKeys(msg)
+	case planPreviewPhase:
+		return m.handlePlanPreviewKeys(msg)
+	case prunePhase:
+		return m.handlePruneKeys(msg)
+	case retryFailedPhase:
+		return m.handleRetryKeys(msg)
+	case defaultsPhase:
+		return m.handleDefaultsKeys(msg)
 	case postInstallConfirmationPhase:
 		return m.handlePostInstallConfirmationKeys(msg)
 	case installCompletePhase, errorPhase:
@@ -471,12 +730,7 @@ func (m *Model) handleSelectOptionKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if !ok {
 			return m, nil
 		}
-		m.selectedProfile = selectedProfile
-		m.nav.Push(loadingPackagesPhase)
-		return m, tea.Batch(
-			m.spinner.Tick,
-			m.service.loadPackagesCmd(m.dotfilesPath, m.selectedProfile.Path),
-		)
+		return m, m.selectProfile(selectedProfile)
 	}
 
 	// Delegate other keys to the list component
@@ -485,19 +739,79 @@ func (m *Model) handleSelectOptionKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m *Model) handleConfirmationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+func (m *Model) handlePostInstallConfirmationKeys(
+	msg tea.KeyMsg,
+) (tea.Model, tea.Cmd) {
+	log.Println("profiles: handlePostInstallConfirmationKeys started")
 	switch {
 	case key.Matches(msg, m.keys.Enter):
-		log.Printf(
-			"Confirmed installation for profile: %s",
-			m.selectedProfile.Name,
-		)
-		// Instead of starting the install directly, check for yay first.
-		m.nav.Push(checkingYayPhase)
-		return m, m.service.CheckPkgMgrCmd()
+		log.Println("profiles: handlePostInstallConfirmationKeys: Accept")
+		return m, m.runPostInstall()
 
 	case key.Matches(msg, m.keys.Back):
-		m.nav.Reset(selectOptionPhase)
+		log.Println("profiles: handlePostInstallConfirmationKeys: Decline")
+
+		// User chose to skip, go to the final screen.
+		return m, m.complete()
+	}
+	return m, nil
+}
+
+func (m *Model) selectProfile(p profileItem) tea.Cmd {
+	m.selectedProfile = p
+	if m.resume != nil && m.resume.Profile != p.Name {
+		m.resume = nil
+	}
+	m.store.Update(func(s *state.State) { s.StartProfile(p.Name) })
+
+	backends, err := m.service.BackendConfig(m.dotfilesPath, p.Profile)
+	if err == nil {
+		err = m.service.UsePackageManager(p.PackageManager, backends)
+	}
+	if err != nil {
+		return func() tea.Msg { return errMsg{err} }
+	}
+
+	m.nav.Push(loadingPackagesPhase)
+	return tea.Batch(
+		m.spinner.Tick,
+		m.service.loadPackagesCmd(m.dotfilesPath, m.selectedProfile.Profile),
+	)
+}
+
+// previewPlan works out the install plan for the selected packages before
+// anything is changed.
+func (m *Model) previewPlan() tea.Cmd {
+	m.toInstall = m.selectedPackages()
+	m.nav.Push(planningPhase)
+
+	return tea.Batch(
+		m.spinner.Tick,
+		m.service.buildPlanCmd(
+			m.dotfilesPath,
+			m.selectedProfile.StowDirs,
+			m.toInstall,
+			m.packageSets,
+		),
+	)
+}
+
+func (m *Model) handlePlanReadyMsg(msg planReadyMsg) (tea.Model, tea.Cmd) {
+	m.nav.Pop()
+	m.nav.Push(planPreviewPhase)
+	m.viewport.SetContent(msg.plan.String())
+	m.viewport.GotoTop()
+
+	return m, nil
+}
+
+func (m *Model) handlePlanPreviewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Enter):
+		return m, m.confirmInstall()
+
+	case key.Matches(msg, m.keys.Back):
+		m.nav.Pop()
 		return m, nil
 	}
 
@@ -507,42 +821,36 @@ func (m *Model) handleConfirmationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m *Model) handlePostInstallConfirmationKeys(
-	msg tea.KeyMsg,
-) (tea.Model, tea.Cmd) {
-	log.Println("profiles: handlePostInstallConfirmationKeys started")
-	switch {
-	case key.Matches(msg, m.keys.Enter):
-		log.Println("profiles: handlePostInstallConfirmationKeys: Accept")
-
-		m.nav.Push(postInstallRunningPhase)
-
-		roles := make([]string, 0, len(m.selectedProfile.Roles))
-		for _, r := range m.selectedProfile.Roles {
-			r = strings.TrimSpace(r)
-			if r != "" {
-				roles = append(roles, r)
-			}
-		}
-		log.Printf("profiles: handlePostInstallConfirmationKeys: roles: %v", roles)
-		env := map[string]string{
-			"MACHINE_PROFILES": strings.Join(roles, ","),
-		}
+func (m *Model) confirmInstall() tea.Cmd {
+	m.toInstall = m.selectedPackages()
+	// A resumed run must not install what the user unticked.
+	deselected := slices.DeleteFunc(slices.Clone(m.profilePackages), func(pkg string) bool {
+		return !m.deselected[pkg]
+	})
+	m.store.Update(func(s *state.State) { s.Deselected = deselected })
+	log.Printf(
+		"Confirmed installation for profile: %s",
+		m.selectedProfile.Name,
+	)
+	// Instead of starting the install directly, make sure the package
+	// manager is ready first.
+	m.nav.Push(checkingPkgMgrPhase)
+	return m.service.CheckPkgMgrCmd()
+}
 
-		return m, m.service.RunPostInstallCmd(
-			m.dotfilesPath,
-			*m.selectedProfile.PostInstall,
-			env,
-		)
+func (m *Model) runPostInstall() tea.Cmd {
+	m.nav.Push(postInstallRunningPhase)
 
-	case key.Matches(msg, m.keys.Back):
-		log.Println("profiles: handlePostInstallConfirmationKeys: Decline")
+	facts := m.service.Facts()
+	env := m.selectedProfile.PostInstallEnv(facts)
+	log.Printf("profiles: runPostInstall: env: %v", env)
 
-		// User chose to skip, go to the final screen.
-		m.nav.Push(installCompletePhase)
-		return m, nil
+	cmd, err := m.selectedProfile.PostInstall.Render(m.selectedProfile.Machine(facts))
+	if err != nil {
+		return func() tea.Msg { return postInstallCompleteMsg{err: err} }
 	}
-	return m, nil
+
+	return m.service.RunPostInstallCmd(m.dotfilesPath, cmd, env)
 }
 
 func (m *Model) handleFinalPhaseKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -551,6 +859,9 @@ func (m *Model) handleFinalPhaseKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if key.Matches(msg, m.keys.Enter) {
 			return m, func() tea.Msg { return types.PhaseFinished{} }
 		}
+		if key.Matches(msg, m.keys.Prune) {
+			return m.startPrune()
+		}
 	case errorPhase:
 		if key.Matches(msg, m.keys.Enter, m.keys.Back) {
 			m.nav.Reset(selectOptionPhase)
@@ -559,55 +870,135 @@ func (m *Model) handleFinalPhaseKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m *Model) handleYayCheckResult(msg yayCheckResultMsg) (tea.Model, tea.Cmd) {
+// complete shows the summary. Unattended runs finish the phase right away
+// instead of waiting for Enter.
+func (m *Model) complete() tea.Cmd {
+	m.nav.Push(installCompletePhase)
+	if m.answers != nil {
+		return func() tea.Msg { return types.PhaseFinished{} }
+	}
+
+	return nil
+}
+
+// packageManagerName names the backend packages are installed with, for the
+// checking and bootstrap screens.
+func (m *Model) packageManagerName() string {
+	pm, err := m.service.PackageManager()
+	if err != nil {
+		return "package manager"
+	}
+
+	return pm.Name()
+}
+
+func (m *Model) handlePkgMgrCheckResult(msg pkgMgrCheckResultMsg) (tea.Model, tea.Cmd) {
 	if msg.isInstalled {
-		log.Println("profiles: yay is already installed.")
-		// Yay exists, proceed directly to package installation
+		log.Printf("profiles: %s is ready.", m.packageManagerName())
+		// The package manager is ready, proceed directly to package installation
 		return m.startPackageInstallation()
 	}
 
-	log.Println("profiles: yay not found, starting installation.")
-	m.nav.Push(installingYayPhase)
-	// Reset the log buffer for the yay installation view
+	log.Printf("profiles: setting up %s.", m.packageManagerName())
+	m.nav.Push(settingUpPkgMgrPhase)
+	// Reset the log buffer for the package manager setup view
 	m.logBuf.Reset()
 	m.viewport.SetContent("")
 	return m, m.service.InstallPkgMgrCmd()
 }
 
-// Add this new handler function
-func (m *Model) handleYayInstallResult(msg yayInstallResultMsg) (tea.Model, tea.Cmd) {
+// handlePkgMgrSetupResult starts the installation once the package manager
+// is set up.
+func (m *Model) handlePkgMgrSetupResult(msg pkgMgrSetupResultMsg) (tea.Model, tea.Cmd) {
 	if msg.err != nil {
-		m.err = fmt.Errorf("failed to install yay: %w", msg.err)
+		m.err = fmt.Errorf("failed to set up %s: %w", m.packageManagerName(), msg.err)
 		m.nav.Push(errorPhase)
 		return m, nil
 	}
-	log.Println("profiles: yay installed successfully.")
-	// Yay is now installed, proceed to package installation
+	log.Printf("profiles: %s set up successfully.", m.packageManagerName())
+	// The package manager is now ready, proceed to package installation
 	return m.startPackageInstallation()
 }
 
 // Create a new helper function to start the actual package installation.
 // This avoids duplicating code.
 func (m *Model) startPackageInstallation() (tea.Model, tea.Cmd) {
-	m.nav.Reset(installingPackagesPhase) // Use Reset to clear nav history like "installing yay"
-	m.currentPackageIndex = 0
+	m.nav.Reset(installingPackagesPhase) // Use Reset to clear nav history like "setting up the package manager"
 	m.packagesSucceeded = nil
 	m.packagesFailed = nil
+	m.installBatches = nil
+	m.installQueue = nil
+	m.failures = make(map[string]packageFailure)
 	m.logBuf.Reset()
 
 	// Stow dotfiles first
 	stowCmd := m.service.stowCmd(m.dotfilesPath, m.selectedProfile.StowDirs)
 
-	if len(m.packagesToInstall) == 0 {
-		m.nav.Push(installCompletePhase)
-		return m, stowCmd
+	if len(m.toInstall) == 0 {
+		return m, tea.Sequence(stowCmd, m.complete())
 	}
 
-	firstPackage := m.packagesToInstall[0]
-	return m, tea.Batch(
-		stowCmd,
-		m.service.installPackageCmd(firstPackage),
-	)
+	total := len(m.toInstall)
+	m.store.Update(func(s *state.State) { s.PackageCount = total })
+
+	pending := m.skipResumedPackages()
+	if CountTools(pending) > 0 {
+		return m, tea.Batch(stowCmd, m.service.checkToolsCmd(pending))
+	}
+
+	return m, tea.Batch(stowCmd, m.queuePackages(pending))
+}
+
+// handleToolsCheckedMsg counts the tools that are already installed as
+// succeeded and queues the rest.
+func (m *Model) handleToolsCheckedMsg(msg toolsCheckedMsg) (tea.Model, tea.Cmd) {
+	var pending []string
+	for _, pkg := range msg.pending {
+		if !msg.installed[pkg] {
+			pending = append(pending, pkg)
+			continue
+		}
+		log.Printf("profiles: skipping %s, already installed", pkg)
+		m.recordPackage(pkg, nil)
+	}
+
+	return m, m.queuePackages(pending)
+}
+
+// queuePackages splits pending into batches and single installs and starts
+// the first.
+func (m *Model) queuePackages(pending []string) tea.Cmd {
+	m.installBatches = m.packageSets.Batches(pending)
+	m.installQueue = UnbatchedPackages(pending, m.installBatches)
+
+	_, cmd := m.installNext()
+	return cmd
+}
+
+// skipResumedPackages carries over the packages a resumed run already
+// installed and returns the packages still to install, including the ones
+// that failed before.
+func (m *Model) skipResumedPackages() []string {
+	if m.resume == nil {
+		return m.toInstall
+	}
+
+	var pending []string
+	for _, pkg := range m.toInstall {
+		outcome, ok := m.resume.Packages[pkg]
+		switch {
+		case ok && outcome == state.PackageOK:
+			log.Printf("profiles: skipping %s, already installed", pkg)
+			m.packagesSucceeded = append(m.packagesSucceeded, pkg)
+		case ok:
+			log.Printf("profiles: retrying %s, failed in the previous run", pkg)
+			pending = append(pending, pkg)
+		default:
+			pending = append(pending, pkg)
+		}
+	}
+
+	return pending
 }
 
 func (m *Model) View() string {
@@ -630,8 +1021,29 @@ func (m *Model) View() string {
 		return m.spinner.View() + fmt.Sprintf(" Loading packages for %s...", m.selectedProfile.Name)
 
 	case confirmationPhase:
-		header := fmt.Sprintf("Ready to install profile '%s'?", m.selectedProfile.Name)
-		help := styles.SubtleTextStyle.Render("Press Enter to confirm, Esc to go back, ↑/↓ to scroll.")
+		return m.checklistView()
+
+	case planningPhase:
+		return m.spinner.View() + " Working out what will change..."
+
+	case readingDefaultsPhase:
+		return m.spinner.View() + " Reading macOS defaults..."
+
+	case defaultsPhase:
+		help := styles.SubtleTextStyle.Render("Press Enter to apply, Esc to skip, ↑/↓ to scroll.")
+
+		return lipgloss.JoinVertical(lipgloss.Left,
+			styles.TitleStyle.Render("macOS defaults (current → desired)"),
+			styles.BlurredBorderStyle.Render(m.viewport.View()),
+			help,
+		)
+
+	case applyingDefaultsPhase:
+		return m.spinner.View() + " Applying macOS defaults and restarting Dock and Finder..."
+
+	case planPreviewPhase:
+		header := fmt.Sprintf("Plan for profile '%s'", m.selectedProfile.Name)
+		help := styles.SubtleTextStyle.Render("Press Enter to install, Esc to go back, ↑/↓ to scroll.")
 
 		return lipgloss.JoinVertical(lipgloss.Left,
 			styles.TitleStyle.Render(header),
@@ -639,14 +1051,14 @@ func (m *Model) View() string {
 			help,
 		)
 
-	case checkingYayPhase:
-		return m.spinner.View() + " Checking for AUR helper (yay)..."
+	case checkingPkgMgrPhase:
+		return m.spinner.View() + fmt.Sprintf(" Checking for the package manager (%s)...", m.packageManagerName())
 
-	case installingYayPhase:
-		header := styles.TitleStyle.Render("Installing AUR Helper (yay)")
+	case settingUpPkgMgrPhase:
+		header := styles.TitleStyle.Render(fmt.Sprintf("Setting up the package manager (%s)", m.packageManagerName()))
 		help := styles.SubtleTextStyle.Render("Please wait, this may take a while...")
 
-		// The command output from InstallYayCmd will be in the viewport via tea.ExecProcess
+		// The bootstrap output will be in the viewport via tea.ExecProcess
 		return lipgloss.JoinVertical(
 			lipgloss.Left,
 			header,
@@ -655,22 +1067,17 @@ func (m *Model) View() string {
 		)
 
 	case installingPackagesPhase:
-		// Show which package is currently being installed and the progress.
-		total := len(m.packagesToInstall)
-		current := m.currentPackageIndex + 1
-		if current > total {
-			current = total
-		}
+		// Show what is currently being installed and the progress.
+		total := len(m.toInstall)
+		done := len(m.packagesSucceeded) + len(m.packagesFailed)
 
-		var pkg string
-		if m.currentPackageIndex < total && total > 0 {
-			pkg = m.packagesToInstall[m.currentPackageIndex]
-		} else {
-			pkg = "(finalizing)"
+		current := m.installing
+		if current == "" {
+			current = "(finalizing)"
 		}
 
 		header := styles.TitleStyle.Render(
-			fmt.Sprintf("Installing (%d/%d): %s", current, total, pkg),
+			fmt.Sprintf("Installing (%d/%d done): %s", done, total, current),
 		)
 		help := styles.SubtleTextStyle.Render("Please wait, this may take a while...")
 
@@ -685,6 +1092,9 @@ func (m *Model) View() string {
 			help,
 		)
 
+	case retryFailedPhase:
+		return m.retryView()
+
 	case postInstallConfirmationPhase:
 		desc := m.selectedProfile.PostInstall.Description
 		header := styles.TitleStyle.Render("Run Post-Install Script?")
@@ -716,6 +1126,9 @@ func (m *Model) View() string {
 		var summary strings.Builder
 		summary.WriteString(styles.SuccessStyle.Render("✅ Installation Complete!"))
 		summary.WriteString(fmt.Sprintf("\n\nSucceeded: %d, Failed: %d\n", len(m.packagesSucceeded), len(m.packagesFailed)))
+		if ok, failed := CountTools(m.packagesSucceeded), CountTools(m.packagesFailed); ok+failed > 0 {
+			summary.WriteString(fmt.Sprintf("Tools: %d succeeded, %d failed\n", ok, failed))
+		}
 
 		if len(m.packagesFailed) > 0 {
 			summary.WriteString("\nFailed packages:\n")
@@ -724,9 +1137,21 @@ func (m *Model) View() string {
 			}
 		}
 
-		summary.WriteString("\n" + styles.SubtleTextStyle.Render("Press Enter to return to the main menu."))
+		if m.pruneSummary != "" {
+			summary.WriteString("\n" + m.pruneSummary + "\n")
+		}
+
+		summary.WriteString("\n" + styles.SubtleTextStyle.Render(
+			"Press Enter to return to the main menu, p to remove packages that are not in the profile.",
+		))
 		return summary.String()
 
+	case findingPrunePhase:
+		return m.spinner.View() + " Looking for packages that are not in the profile..."
+
+	case prunePhase:
+		return m.pruneView()
+
 	case errorPhase:
 		return styles.ErrorStyle.Width(m.width).Render(fmt.Sprintf("Error: %v", m.err))
 
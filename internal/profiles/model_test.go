@@ -0,0 +1,64 @@
+package profiles
+
+import (
+	"archsetup/internal/answers"
+	"archsetup/internal/state"
+	"archsetup/internal/types"
+	"slices"
+	"testing"
+)
+
+func TestModel_Complete(t *testing.T) {
+	t.Run("unattended runs finish the phase without a key press", func(t *testing.T) {
+		m := New(types.DefaultKeys(), setupService(&mockExecutor{}, &mockFileSystem{}), nil).(*Model)
+		m.Update(answers.LoadedMsg{Answers: answers.Answers{Profile: "Desktop"}})
+		m.selectedProfile = profileItem{Profile: Profile{Name: "Desktop"}}
+
+		_, cmd := m.startPostInstall()
+
+		if m.nav.Current() != installCompletePhase {
+			t.Errorf("Expected the summary, got phase %v", m.nav.Current())
+		}
+		if cmd == nil {
+			t.Fatal("Expected the phase to finish, but got no command")
+		}
+		if _, ok := cmd().(types.PhaseFinished); !ok {
+			t.Errorf("Expected PhaseFinished, but got %T", cmd())
+		}
+	})
+
+	t.Run("interactive runs wait on the summary", func(t *testing.T) {
+		m := New(types.DefaultKeys(), setupService(&mockExecutor{}, &mockFileSystem{}), nil).(*Model)
+		m.selectedProfile = profileItem{Profile: Profile{Name: "Desktop"}}
+
+		_, cmd := m.startPostInstall()
+
+		if cmd != nil {
+			t.Errorf("Expected to wait for Enter, but got %T", cmd())
+		}
+	})
+}
+
+func TestModel_Resume(t *testing.T) {
+	t.Run("it retries packages that failed and keeps unticked ones out", func(t *testing.T) {
+		m := New(types.DefaultKeys(), setupService(&mockExecutor{}, &mockFileSystem{}), nil).(*Model)
+		m.Update(ResumeMsg{
+			Profile:    "Desktop",
+			Packages:   map[string]string{"git": state.PackageOK, "vim": state.PackageFailed},
+			Deselected: []string{"emacs"},
+		})
+
+		m.handlePackagesLoadedMsg(packagesLoadedMsg{packages: []string{"git", "vim", "emacs", "tmux"}})
+
+		if want := []string{"git", "vim", "tmux"}; !slices.Equal(m.toInstall, want) {
+			t.Errorf("Expected to install %v, but got %v", want, m.toInstall)
+		}
+		pending := m.skipResumedPackages()
+		if want := []string{"vim", "tmux"}; !slices.Equal(pending, want) {
+			t.Errorf("Expected %v to be pending, but got %v", want, pending)
+		}
+		if !slices.Equal(m.packagesSucceeded, []string{"git"}) || len(m.packagesFailed) != 0 {
+			t.Errorf("Expected only git to carry over, got %v succeeded and %v failed", m.packagesSucceeded, m.packagesFailed)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// includeDirective pulls another package list into the current one. The path
+// is relative to the directory of the list that contains it.
+const includeDirective = "@include"
+
+// entryPrefixes are the backends a package list entry can be routed to with
+// a "prefix:name" entry.
+var entryPrefixes = []string{
+	SourceAUR, SourceFlatpak, SourceCask, SourceMas, SourcePip, SourceCargo, SourceGo, SourceNpm,
+}
+
+// prefixLike matches what looks like an entry prefix: a lowercase word. Names
+// that merely contain a colon, such as apt's multiarch "libc6:i386", do not.
+var prefixLike = regexp.MustCompile(`^[a-z]+$`)
+
+// toolInstallers are the tool installers of the tool prefixes.
+var toolInstallers = map[string]string{
+	SourceCargo: pkgmgr.Cargo,
+	SourceGo:    pkgmgr.Go,
+	SourceNpm:   pkgmgr.Npm,
+	SourcePip:   pkgmgr.Pipx,
+}
+
+// IsTool reports whether the entry is installed by a language toolchain, as
+// the entries of [profiles.tools] are.
+func IsTool(entry string) bool {
+	prefix, _ := ParseEntry(entry)
+	_, ok := toolInstallers[prefix]
+	return ok
+}
+
+// CountTools returns how many of the entries are tools.
+func CountTools(entries []string) int {
+	n := 0
+	for _, entry := range entries {
+		if IsTool(entry) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ParseEntry splits a package list entry such as "flatpak:com.spotify.Client"
+// into its backend prefix and package name. Entries without a known prefix
+// return an empty prefix and the entry unchanged.
+func ParseEntry(entry string) (prefix, name string) {
+	before, after, found := strings.Cut(entry, ":")
+	if !found || !slices.Contains(entryPrefixes, before) {
+		return "", entry
+	}
+
+	return before, after
+}
+
+// PackageName returns the name the backend knows the entry by.
+func PackageName(entry string) string {
+	_, name := ParseEntry(entry)
+	return name
+}
+
+// readPackageList parses the list at fullPath, following @include lines and
+// skipping entries whose condition does not hold on the machine. stack holds
+// the lists currently being read and catches include cycles. A brewfile is
+// read with its own syntax, see parseBrewfile.
+func (s *Service) readPackageList(
+	fullPath string,
+	brewfile bool,
+	machine Machine,
+	stack []string,
+) ([]string, error) {
+	if slices.Contains(stack, fullPath) {
+		chain := append(slices.Clone(stack), fullPath)
+		return nil, fmt.Errorf("package list include cycle: %s", strings.Join(chain, " -> "))
+	}
+	stack = append(stack, fullPath)
+
+	file, err := s.fs.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"could not open package list %s: %w",
+			fullPath,
+			err,
+		)
+	}
+	defer file.Close()
+
+	if brewfile {
+		bf, err := parseBrewfile(file, fullPath)
+		return bf.Entries, err
+	}
+
+	var packages []string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, cond, err := splitCondition(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", fullPath, lineNo, err)
+		}
+		if line == "" || !cond.Matches(machine) {
+			continue
+		}
+
+		// The directive may be followed by any whitespace, and the path may
+		// contain spaces.
+		if fields := strings.Fields(line); fields[0] == includeDirective {
+			target := strings.TrimSpace(strings.TrimPrefix(line, includeDirective))
+			if target == "" {
+				return nil, fmt.Errorf("%s:%d: %s needs a path", fullPath, lineNo, includeDirective)
+			}
+
+			includedPath := filepath.Join(filepath.Dir(fullPath), target)
+			included, err := s.readPackageList(
+				includedPath,
+				isBrewfile(includedPath),
+				machine,
+				stack,
+			)
+			if err != nil {
+				return nil, err
+			}
+			packages = append(packages, included...)
+			continue
+		}
+
+		before, _, found := strings.Cut(line, ":")
+		if found && prefixLike.MatchString(before) && !slices.Contains(entryPrefixes, before) {
+			return nil, fmt.Errorf(
+				"%s:%d: unknown package prefix %q (expected one of %s)",
+				fullPath,
+				lineNo,
+				before,
+				strings.Join(entryPrefixes, ", "),
+			)
+		}
+
+		packages = append(packages, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"error reading package list: %s: %w",
+			fullPath,
+			err,
+		)
+	}
+
+	return packages, nil
+}
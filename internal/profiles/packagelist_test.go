@@ -0,0 +1,112 @@
+package profiles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		entry, prefix, name string
+	}{
+		{"git", "", "git"},
+		{"aur:spotify", SourceAUR, "spotify"},
+		{"flatpak:com.discordapp.Discord", SourceFlatpak, "com.discordapp.Discord"},
+		{"cask:wezterm", SourceCask, "wezterm"},
+		{"pip:black", SourcePip, "black"},
+	}
+
+	for _, tt := range tests {
+		t.Run("it parses "+tt.entry, func(t *testing.T) {
+			prefix, name := ParseEntry(tt.entry)
+			if prefix != tt.prefix || name != tt.name {
+				t.Errorf("Expected (%q, %q), but got (%q, %q)", tt.prefix, tt.name, prefix, name)
+			}
+		})
+	}
+}
+
+func TestService_LoadPackagesIncludes(t *testing.T) {
+	t.Run("it reads included lists relative to the including file", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/lists/desktop.txt":    "git\n@include common/dev.txt\naur:spotify\n",
+			"/dots/lists/common/dev.txt": "# dev tools\ngo\npip:black\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackages("/dots", "lists/desktop.txt", Machine{})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []string{"git", "go", "pip:black", "aur:spotify"}
+		if !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+	})
+
+	t.Run("it accepts tabs and repeated spaces after the directive", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/a.txt": "@include\tb.txt\n@include   c.txt\n",
+			"/dots/b.txt": "git\n",
+			"/dots/c.txt": "zsh\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackages("/dots", "a.txt", Machine{})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if want := []string{"git", "zsh"}; !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+	})
+
+	t.Run("it reports include cycles", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/a.txt": "@include b.txt\n",
+			"/dots/b.txt": "@include a.txt\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		_, err := service.LoadPackages("/dots", "a.txt", Machine{})
+
+		if err == nil || !strings.Contains(err.Error(), "/dots/a.txt -> /dots/b.txt -> /dots/a.txt") {
+			t.Errorf("Expected an include cycle error, but got %v", err)
+		}
+	})
+
+	t.Run("it rejects unknown prefixes", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/a.txt": "git\nsnap:firefox\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		_, err := service.LoadPackages("/dots", "a.txt", Machine{})
+
+		if err == nil || !strings.Contains(err.Error(), `a.txt:2: unknown package prefix "snap"`) {
+			t.Errorf("Expected an unknown prefix error, but got %v", err)
+		}
+	})
+
+	t.Run("it keeps native names that contain a colon", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/a.txt": "libc6:i386\nlibgl1-mesa-dri:i386\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		packages, err := service.LoadPackages("/dots", "a.txt", Machine{})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if want := []string{"libc6:i386", "libgl1-mesa-dri:i386"}; !reflect.DeepEqual(packages, want) {
+			t.Errorf("Expected %v, but got %v", want, packages)
+		}
+		if prefix, name := ParseEntry(packages[0]); prefix != "" || name != "libc6:i386" {
+			t.Errorf("Expected a native entry, but got prefix %q and name %q", prefix, name)
+		}
+	})
+}
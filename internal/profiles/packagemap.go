@@ -0,0 +1,156 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"archsetup/internal/utils"
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// packageMapFileName is the optional file in the dotfiles repo that maps
+// logical package names to per-backend names.
+const packageMapFileName = "package_map.toml"
+
+// PackageMap maps logical package names to the names each backend knows them
+// by, so one package list serves every OS:
+//
+//	[fd]
+//	apt = "fd-find"
+//	dnf = "fd-find"
+//
+//	[docker]
+//	apt = ["docker.io", "docker-compose"]
+//	brew = "cask:docker"
+//
+// Backends without an entry install the logical name; an empty list skips
+// the package on that backend. The pacman entry also applies to yay and
+// paru.
+type PackageMap map[string]map[string]mappedNames
+
+// mappedNames is a package name or a list of them.
+type mappedNames []string
+
+// UnmarshalTOML accepts a string or an array of strings. An empty string is
+// an empty list.
+func (n *mappedNames) UnmarshalTOML(value any) error {
+	switch v := value.(type) {
+	case string:
+		*n = mappedNames{}
+		if v != "" {
+			*n = mappedNames{v}
+		}
+		return nil
+
+	case []any:
+		*n = make(mappedNames, 0, len(v))
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok || name == "" {
+				return fmt.Errorf("expected package names, but got %v", item)
+			}
+			*n = append(*n, name)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("expected a package name or a list of them, but got %v", value)
+}
+
+// validate reports the first backend name that does not exist.
+func (m PackageMap) validate() error {
+	for _, logical := range slices.Sorted(maps.Keys(m)) {
+		for backend := range m[logical] {
+			if !slices.Contains(pkgmgr.Names(), backend) {
+				return fmt.Errorf(
+					"%s: unknown package manager %q (available: %s)",
+					logical,
+					backend,
+					strings.Join(pkgmgr.Names(), ", "),
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Resolve replaces the logical names among packages with what the first of
+// backends with an entry calls them. Prefixed entries are left alone, and
+// packages are kept in order without duplicates.
+func (m PackageMap) Resolve(packages []string, backends ...string) []string {
+	var resolved []string
+	for _, pkg := range packages {
+		names, backend, ok := m.lookup(pkg, backends)
+		if !ok {
+			resolved = utils.MergeUnique(resolved, []string{pkg})
+			continue
+		}
+
+		if len(names) == 0 {
+			log.Printf("profiles: %s is not installed with %s, skipping", pkg, backend)
+		}
+		resolved = utils.MergeUnique(resolved, names)
+	}
+
+	return resolved
+}
+
+func (m PackageMap) lookup(pkg string, backends []string) (names []string, backend string, ok bool) {
+	if prefix, _ := ParseEntry(pkg); prefix != "" {
+		return nil, "", false
+	}
+
+	for _, backend := range backends {
+		if names, ok := m[pkg][backend]; ok {
+			return names, backend, true
+		}
+	}
+
+	return nil, "", false
+}
+
+// loadPackageMap reads package_map.toml from the dotfiles repo. A repo
+// without one has an empty map.
+func (s *Service) loadPackageMap(dotfilesPath string) (PackageMap, error) {
+	path := filepath.Join(dotfilesPath, packageMapFileName)
+	file, err := s.fs.Open(path)
+	if s.fs.IsNotExist(err) {
+		return PackageMap{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", packageMapFileName, err)
+	}
+	defer file.Close()
+
+	var m PackageMap
+	if _, err := toml.NewDecoder(file).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid %s format: %w", packageMapFileName, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", packageMapFileName, err)
+	}
+
+	return m, nil
+}
+
+// mapPackages resolves the logical names in packages for the selected
+// package manager. Without one, the names stay as they are.
+func (s *Service) mapPackages(dotfilesPath string, packages []string) ([]string, error) {
+	pm, err := s.PackageManager()
+	if err != nil {
+		return packages, nil
+	}
+
+	m, err := s.loadPackageMap(dotfilesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Resolve(packages, pm.Name(), pkgmgr.Repo(pm).Name()), nil
+}
@@ -0,0 +1,84 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPackageMap = `
+[fd]
+apt = "fd-find"
+dnf = "fd-find"
+
+[docker]
+apt = ["docker.io", "docker-compose"]
+brew = "cask:docker"
+
+[pacman-contrib]
+pacman = "pacman-contrib"
+apt = ""
+`
+
+func TestPackageMap_Resolve(t *testing.T) {
+	mockFS := &mockFileSystem{openFiles: map[string]string{"/dots/package_map.toml": testPackageMap}}
+	service := setupService(&mockExecutor{}, mockFS)
+	m, err := service.loadPackageMap("/dots")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	packages := []string{"git", "fd", "docker", "pacman-contrib", "flatpak:fd"}
+
+	tests := []struct {
+		backends []string
+		want     []string
+	}{
+		{[]string{pkgmgr.Apt}, []string{"git", "fd-find", "docker.io", "docker-compose", "flatpak:fd"}},
+		{[]string{pkgmgr.Homebrew}, []string{"git", "fd", "cask:docker", "pacman-contrib", "flatpak:fd"}},
+		{[]string{pkgmgr.Paru, pkgmgr.Pacman}, []string{"git", "fd", "docker", "pacman-contrib", "flatpak:fd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("it resolves names for "+strings.Join(tt.backends, ", "), func(t *testing.T) {
+			if got := m.Resolve(packages, tt.backends...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestService_LoadPackageMap(t *testing.T) {
+	t.Run("it rejects unknown package managers", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/package_map.toml": "[fd]\nzypper = \"fd\"\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+
+		_, err := service.loadPackageMap("/dots")
+
+		if err == nil || !strings.Contains(err.Error(), `"zypper"`) {
+			t.Errorf("Expected an error naming zypper, but got %v", err)
+		}
+	})
+
+	t.Run("it maps the profile's packages for its package manager", func(t *testing.T) {
+		mockFS := &mockFileSystem{openFiles: map[string]string{
+			"/dots/package_map.toml": testPackageMap,
+			"/dots/cli.txt":          "git\nfd\n",
+		}}
+		service := setupService(&mockExecutor{}, mockFS)
+		if err := service.UsePackageManager(pkgmgr.Dnf, pkgmgr.Config{}); err != nil {
+			t.Fatal(err)
+		}
+
+		packages, err := service.LoadProfilePackages("/dots", Profile{Path: "cli.txt"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(packages, []string{"git", "fd-find"}) {
+			t.Errorf("Expected [git fd-find], but got %v", packages)
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Plan describes what installing a profile will change on this machine.
+type Plan struct {
+	Installed     []string
+	New           []string
+	DownloadSize  int64
+	InstalledSize int64
+	// SizesUnknown lists new packages without size information, such as
+	// AUR packages that are built locally.
+	SizesUnknown []string
+	Stow         []StowPlan
+	Warnings     []string
+}
+
+// StowPlan lists the links stowing one directory will create.
+type StowPlan struct {
+	Dir   string
+	Links []string
+	Err   string
+}
+
+type planReadyMsg struct {
+	plan Plan
+}
+
+// BuildPlan works out which packages are new and how big they are, and which
+// links stowing the profile creates. It is best effort: anything that cannot
+// be determined ends up in Warnings.
+func (s *Service) BuildPlan(
+	dotfilesPath string,
+	stowDirs []string,
+	packages []string,
+	sets PackageSets,
+) Plan {
+	var plan Plan
+
+	if pm, err := s.PackageManager(); err != nil {
+		plan.New = packages
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("installed packages cannot be detected: %v", err))
+	} else {
+		s.planPackages(&plan, pm, packages, sets)
+	}
+	s.planSideBackends(&plan)
+
+	s.planStow(&plan, dotfilesPath, stowDirs)
+
+	return plan
+}
+
+func (s *Service) buildPlanCmd(
+	dotfilesPath string,
+	stowDirs []string,
+	packages []string,
+	sets PackageSets,
+) tea.Cmd {
+	return func() tea.Msg {
+		return planReadyMsg{plan: s.BuildPlan(dotfilesPath, stowDirs, packages, sets)}
+	}
+}
+
+// planPackages asks the package manager which packages are installed and
+// how big the new repo packages are.
+func (s *Service) planPackages(
+	plan *Plan,
+	pm pkgmgr.PackageManager,
+	packages []string,
+	sets PackageSets,
+) {
+	prefixes := nativePrefixes(pm)
+
+	var names []string
+	for _, pkg := range packages {
+		if prefix, name := ParseEntry(pkg); prefix == "" || slices.Contains(prefixes, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	installed, err := pm.IsInstalled(names)
+	if err != nil {
+		plan.New = packages
+		plan.Warnings = append(plan.Warnings, err.Error())
+		return
+	}
+	splitInstalled(plan, packages, installed, prefixes)
+
+	var repo []string
+	for _, pkg := range plan.New {
+		if sets.Source(pkg) == SourceRepo {
+			repo = append(repo, pkg)
+		} else {
+			plan.SizesUnknown = append(plan.SizesUnknown, pkg)
+		}
+	}
+	if len(repo) == 0 {
+		return
+	}
+
+	sizes, err := pkgmgr.Repo(pm).Info(repo)
+	if err != nil {
+		log.Printf("profiles: could not get package sizes: %v", err)
+	}
+	for _, pkg := range repo {
+		size, ok := sizes[pkg]
+		if !ok {
+			plan.SizesUnknown = append(plan.SizesUnknown, pkg)
+			continue
+		}
+		plan.DownloadSize += size.DownloadSize
+		plan.InstalledSize += size.InstalledSize
+	}
+}
+
+// planSideBackends moves the Flatpak apps and tools that are already
+// installed from New to Installed. Those whose backend is not installed yet
+// all stay new.
+func (s *Service) planSideBackends(plan *Plan) {
+	installed, errs := s.installedSideEntries(plan.New)
+	for _, err := range errs {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+
+	var stillNew []string
+	for _, pkg := range plan.New {
+		if installed[pkg] {
+			plan.Installed = append(plan.Installed, pkg)
+			plan.SizesUnknown = slices.DeleteFunc(plan.SizesUnknown, func(p string) bool { return p == pkg })
+		} else {
+			stillNew = append(stillNew, pkg)
+		}
+	}
+	plan.New = stillNew
+}
+
+// installedSideEntries returns the Flatpak apps and tools among packages
+// that are already installed, asking each backend once. Backends that are not
+// installed yet, or cannot list their packages, report nothing.
+func (s *Service) installedSideEntries(packages []string) (map[string]bool, []error) {
+	var prefixes []string
+	names := make(map[string][]string)
+	for _, pkg := range packages {
+		if prefix, name := ParseEntry(pkg); s.sideBackend(prefix) != nil {
+			if _, ok := names[prefix]; !ok {
+				prefixes = append(prefixes, prefix)
+			}
+			names[prefix] = append(names[prefix], name)
+		}
+	}
+
+	var errs []error
+	installed := make(map[string]bool)
+	for _, prefix := range prefixes {
+		backend := s.sideBackend(prefix)
+		if !backend.Detect() {
+			continue
+		}
+
+		found, err := backend.IsInstalled(names[prefix])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for name := range found {
+			installed[prefix+":"+name] = true
+		}
+	}
+
+	return installed, errs
+}
+
+// sideBackend returns the backend that installs entries with the prefix
+// next to the system package manager: Flatpak or a tool installer. It is nil
+// for other prefixes.
+func (s *Service) sideBackend(prefix string) pkgmgr.PackageManager {
+	if prefix == SourceFlatpak {
+		return pkgmgr.NewFlatpak(s.exec, s.managerConfig)
+	}
+	if name, ok := toolInstallers[prefix]; ok {
+		tool, _ := pkgmgr.NewTool(name, s.exec)
+		return tool
+	}
+
+	return nil
+}
+
+// nativePrefixes are the entry prefixes the package manager installs itself.
+func nativePrefixes(pm pkgmgr.PackageManager) []string {
+	switch {
+	case pkgmgr.IsAURHelper(pm):
+		return []string{SourceAUR}
+	case pm.Name() == pkgmgr.Homebrew:
+		return []string{SourceCask, SourceMas}
+	}
+
+	return nil
+}
+
+// splitInstalled sorts packages into installed and new ones. Prefixed entries
+// only count as installed when the package manager handles their prefix.
+func splitInstalled(plan *Plan, packages []string, installed map[string]bool, prefixes []string) {
+	for _, pkg := range packages {
+		prefix, name := ParseEntry(pkg)
+		listed := prefix == "" || slices.Contains(prefixes, prefix)
+		if listed && installed[name] {
+			plan.Installed = append(plan.Installed, pkg)
+		} else {
+			plan.New = append(plan.New, pkg)
+		}
+	}
+}
+
+// planStow asks stow for a dry run of every directory.
+func (s *Service) planStow(plan *Plan, dotfilesPath string, stowDirs []string) {
+	if len(stowDirs) == 0 {
+		return
+	}
+
+	home, err := s.fs.UserHomeDir()
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("could not get user home dir: %v", err))
+		return
+	}
+
+	for _, dir := range stowDirs {
+		cmd := exec.Command("stow", "-n", "-v", "-t", home, "-R", dir)
+		cmd.Dir = dotfilesPath
+
+		output, err := s.exec.CombinedOutput(cmd)
+		dirPlan := StowPlan{Dir: dir, Links: parseStowLinks(string(output))}
+		if err != nil {
+			dirPlan.Err = strings.TrimSpace(string(output))
+			if dirPlan.Err == "" {
+				dirPlan.Err = err.Error()
+			}
+		}
+		plan.Stow = append(plan.Stow, dirPlan)
+	}
+}
+
+// parseStowLinks returns the link targets from `stow -n -v` output.
+func parseStowLinks(output string) []string {
+	var links []string
+	for _, line := range strings.Split(output, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), "LINK: ")
+		if !ok {
+			continue
+		}
+		target, _, _ := strings.Cut(rest, " => ")
+		links = append(links, target)
+	}
+
+	return links
+}
+
+// FormatSize renders a byte count the way pacman does.
+func FormatSize(bytes int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	value := float64(bytes)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
+
+func (p Plan) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "New packages (%d):\n", len(p.New))
+	for _, pkg := range p.New {
+		fmt.Fprintf(&b, "  + %s\n", pkg)
+	}
+
+	fmt.Fprintf(&b, "\nAlready installed (%d):\n", len(p.Installed))
+	for _, pkg := range p.Installed {
+		fmt.Fprintf(&b, "  = %s\n", pkg)
+	}
+
+	fmt.Fprintf(&b, "\nDownload size:  %s\n", FormatSize(p.DownloadSize))
+	fmt.Fprintf(&b, "Installed size: %s\n", FormatSize(p.InstalledSize))
+	if len(p.SizesUnknown) > 0 {
+		fmt.Fprintf(&b, "Not included (no size known): %s\n", strings.Join(p.SizesUnknown, ", "))
+	}
+
+	if len(p.Stow) > 0 {
+		b.WriteString("\nStow:\n")
+	}
+	for _, dir := range p.Stow {
+		switch {
+		case dir.Err != "":
+			fmt.Fprintf(&b, "  %s: would fail\n", dir.Dir)
+			for _, line := range strings.Split(dir.Err, "\n") {
+				fmt.Fprintf(&b, "      %s\n", line)
+			}
+		case len(dir.Links) == 0:
+			fmt.Fprintf(&b, "  %s: nothing to link\n", dir.Dir)
+		default:
+			fmt.Fprintf(&b, "  %s: %d links\n", dir.Dir, len(dir.Links))
+			for _, link := range dir.Links {
+				fmt.Fprintf(&b, "      ~/%s\n", link)
+			}
+		}
+	}
+
+	if len(p.Warnings) > 0 {
+		b.WriteString("\nWarnings:\n")
+	}
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "  ! %s\n", w)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
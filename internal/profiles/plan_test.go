@@ -0,0 +1,125 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pacmanSiOutput = `Repository      : extra
+Name            : git
+Version         : 2.45.2-1
+Download Size   : 6.50 MiB
+Installed Size  : 27.00 MiB
+
+Repository      : extra
+Name            : zsh
+Download Size   : 512.00 KiB
+Installed Size  : 2.00 MiB
+`
+
+func TestParseStowLinks(t *testing.T) {
+	output := `WARNING: in simulation mode so not modifying filesystem.
+LINK: .zshrc => Developer/dotfiles/zsh/.zshrc
+LINK: .config/zsh => ../Developer/dotfiles/zsh/.config/zsh
+`
+
+	got := parseStowLinks(output)
+
+	if !reflect.DeepEqual(got, []string{".zshrc", ".config/zsh"}) {
+		t.Errorf("Expected both link targets, but got %v", got)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{512, "512.00 B"},
+		{1536, "1.50 KiB"},
+		{3 << 30, "3.00 GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestService_PlanPackages(t *testing.T) {
+	t.Run("it splits installed and new packages and sums repo sizes", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			if strings.Join(cmd.Args, " ") == "pacman -Qq" {
+				return []byte("base\nneovim\n"), nil
+			}
+			return []byte("Name : git\nDownload Size : 6.50 MiB\n\nName : zsh\nDownload Size : 512.00 KiB\n"), nil
+		}}
+		service := setupService(mockExec, &mockFileSystem{})
+		pm, _ := pkgmgr.New(pkgmgr.Yay, mockExec, pkgmgr.Config{})
+		sets := PackageSets{Repo: []string{"git", "zsh", "neovim"}, AUR: []string{"yay-bin"}}
+
+		var plan Plan
+		service.planPackages(&plan, pm, []string{"git", "neovim", "zsh", "yay-bin"}, sets)
+
+		if !reflect.DeepEqual(plan.Installed, []string{"neovim"}) {
+			t.Errorf("Expected neovim to be installed, got %v", plan.Installed)
+		}
+		if !reflect.DeepEqual(plan.New, []string{"git", "zsh", "yay-bin"}) {
+			t.Errorf("Expected git, zsh and yay-bin to be new, got %v", plan.New)
+		}
+		if plan.DownloadSize != 6.5*(1<<20)+512*(1<<10) {
+			t.Errorf("Unexpected download size %d", plan.DownloadSize)
+		}
+		if !reflect.DeepEqual(plan.SizesUnknown, []string{"yay-bin"}) {
+			t.Errorf("Expected the AUR package to have no size, got %v", plan.SizesUnknown)
+		}
+	})
+
+	t.Run("it warns when the installed packages cannot be listed", func(t *testing.T) {
+		mockExec := &mockExecutor{outputErr: errors.New("no pacman")}
+		service := setupService(mockExec, &mockFileSystem{})
+		pm, _ := pkgmgr.New(pkgmgr.Pacman, mockExec, pkgmgr.Config{})
+
+		var plan Plan
+		service.planPackages(&plan, pm, []string{"git"}, PackageSets{})
+
+		if len(plan.Warnings) != 1 || !reflect.DeepEqual(plan.New, []string{"git"}) {
+			t.Errorf("Expected a warning and git as new, got %+v", plan)
+		}
+	})
+}
+
+func TestService_PlanStow(t *testing.T) {
+	t.Run("it records the links of every directory", func(t *testing.T) {
+		mockExec := &mockExecutor{combinedOutput: []byte("LINK: .zshrc => dots/zsh/.zshrc\n")}
+		service := setupService(mockExec, &mockFileSystem{homeDir: "/home/user"})
+
+		var plan Plan
+		service.planStow(&plan, "/dots", []string{"zsh"})
+
+		want := []StowPlan{{Dir: "zsh", Links: []string{".zshrc"}}}
+		if !reflect.DeepEqual(plan.Stow, want) {
+			t.Errorf("Expected %+v, but got %+v", want, plan.Stow)
+		}
+	})
+
+	t.Run("it keeps stow's complaint when a directory would fail", func(t *testing.T) {
+		mockExec := &mockExecutor{
+			combinedOutput:    []byte("existing target is not owned by stow: .zshrc"),
+			combinedOutputErr: errors.New("exit status 1"),
+		}
+		service := setupService(mockExec, &mockFileSystem{homeDir: "/home/user"})
+
+		var plan Plan
+		service.planStow(&plan, "/dots", []string{"zsh"})
+
+		if !strings.Contains(plan.Stow[0].Err, "not owned by stow") {
+			t.Errorf("Expected the stow error, got %+v", plan.Stow)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package profiles
+
+import (
+	"archsetup/internal/styles"
+	"archsetup/internal/system"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// alwaysKeep protects the packages a machine cannot boot, log in, reach the
+// network or install packages without, whatever the profile and keep lists
+// say. The names are Arch's: prune is Arch-only by design, see
+// PruneCandidates.
+var alwaysKeep = []string{
+	// base system and kernels
+	"base",
+	"base-devel",
+	"sudo",
+	"linux",
+	"linux-lts",
+	"linux-zen",
+	"linux-hardened",
+	"linux-firmware",
+	"intel-ucode",
+	"amd-ucode",
+	// boot
+	"mkinitcpio",
+	"dracut",
+	"grub",
+	"efibootmgr",
+	"os-prober",
+	"refind",
+	"limine",
+	"syslinux",
+	// file systems and disks
+	"btrfs-progs",
+	"e2fsprogs",
+	"xfsprogs",
+	"dosfstools",
+	"cryptsetup",
+	"lvm2",
+	// network
+	"networkmanager",
+	"iwd",
+	"dhcpcd",
+	"netctl",
+	"wpa_supplicant",
+	"systemd-resolvconf",
+	"openssh",
+	// package management
+	"pacman",
+	"archlinux-keyring",
+	"yay",
+	"yay-bin",
+	"paru",
+	"paru-bin",
+}
+
+type pruneCandidatesMsg struct {
+	packages []string
+	err      error
+}
+
+type packagesRemovedMsg struct {
+	packages []string
+	err      error
+}
+
+// PruneCandidates returns the explicitly installed packages that are neither
+// in the profile's package list nor kept. Groups in the package list protect
+// their members. Only Arch-like systems are supported by design: candidates
+// come from `pacman -Qqe`, and alwaysKeep only knows Arch package names.
+func (s *Service) PruneCandidates(packages, keep []string) ([]string, error) {
+	info := system.CurrentOSInfo()
+	if !info.IsArchLike() {
+		return nil, errors.New("pruning is only supported on Arch-based systems")
+	}
+
+	return s.archPruneCandidates(packages, keep)
+}
+
+func (s *Service) archPruneCandidates(packages, keep []string) ([]string, error) {
+	output, err := s.exec.Output(exec.Command("pacman", "-Qqe"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list explicitly installed packages: %w", err)
+	}
+
+	var native []string
+	for _, pkg := range packages {
+		if prefix, name := ParseEntry(pkg); prefix == "" || prefix == SourceAUR {
+			native = append(native, name)
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, names := range [][]string{native, keep, alwaysKeep} {
+		for _, name := range names {
+			wanted[name] = true
+		}
+	}
+
+	// pacman exits non-zero for names that are not groups, but still lists
+	// the members of the ones that are.
+	groups, _ := s.exec.Output(exec.Command("pacman", append([]string{"-Sgq"}, native...)...))
+	for _, name := range strings.Fields(string(groups)) {
+		wanted[name] = true
+	}
+
+	var candidates []string
+	for _, name := range strings.Fields(string(output)) {
+		if !wanted[name] {
+			candidates = append(candidates, name)
+		}
+	}
+
+	return candidates, nil
+}
+
+func (s *Service) pruneCandidatesCmd(packages, keep []string) tea.Cmd {
+	return func() tea.Msg {
+		candidates, err := s.PruneCandidates(packages, keep)
+		return pruneCandidatesMsg{packages: candidates, err: err}
+	}
+}
+
+// RemovePackagesCommand builds the command that removes packages with the
+// selected package manager, which asks for confirmation itself.
+func (s *Service) RemovePackagesCommand(packages []string) (*exec.Cmd, error) {
+	pm, err := s.PackageManager()
+	if err != nil {
+		return nil, err
+	}
+
+	return pm.Remove(packages), nil
+}
+
+func (s *Service) removePackagesCmd(packages []string) tea.Cmd {
+	cmd, err := s.RemovePackagesCommand(packages)
+	if err != nil {
+		return func() tea.Msg { return packagesRemovedMsg{packages: packages, err: err} }
+	}
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return packagesRemovedMsg{packages: packages, err: err}
+	})
+}
+
+// startPrune looks for packages that drifted in outside the profile.
+func (m *Model) startPrune() (tea.Model, tea.Cmd) {
+	m.pruneSummary = ""
+	m.nav.Push(findingPrunePhase)
+
+	keep := slices.Concat(m.keep, m.selectedProfile.Keep)
+	return m, tea.Batch(
+		m.spinner.Tick,
+		m.service.pruneCandidatesCmd(m.profilePackages, keep),
+	)
+}
+
+func (m *Model) handlePruneCandidatesMsg(msg pruneCandidatesMsg) (tea.Model, tea.Cmd) {
+	m.nav.Pop()
+
+	if msg.err != nil {
+		log.Printf("profiles: prune failed: %v", msg.err)
+		m.pruneSummary = msg.err.Error()
+		return m, nil
+	}
+	if len(msg.packages) == 0 {
+		m.pruneSummary = "Nothing to prune: every installed package is in the profile."
+		return m, nil
+	}
+
+	// Removal is opt-in: every candidate starts unticked.
+	m.pruneDeselected = make(map[string]bool)
+	items := make([]list.Item, len(msg.packages))
+	for i, pkg := range msg.packages {
+		items[i] = packageItem{name: pkg}
+		m.pruneDeselected[pkg] = true
+	}
+	m.pruneList = list.New(items, packageDelegate{deselected: m.pruneDeselected}, m.width, m.height-8)
+	m.pruneList.SetShowTitle(false)
+	m.pruneList.SetShowHelp(false)
+	m.pruneList.SetShowStatusBar(false)
+
+	m.nav.Push(prunePhase)
+	return m, nil
+}
+
+func (m *Model) handlePruneKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.pruneList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.pruneList, cmd = m.pruneList.Update(msg)
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Toggle):
+		if item, ok := m.pruneList.SelectedItem().(packageItem); ok {
+			m.pruneDeselected[item.name] = !m.pruneDeselected[item.name]
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Enter):
+		remove := m.pruneSelection()
+		if len(remove) == 0 {
+			m.nav.Pop()
+			return m, nil
+		}
+		log.Printf("profiles: removing %s", strings.Join(remove, ", "))
+		return m, m.service.removePackagesCmd(remove)
+
+	case key.Matches(msg, m.keys.Back):
+		if m.pruneList.FilterState() == list.FilterApplied {
+			m.pruneList.ResetFilter()
+			return m, nil
+		}
+		m.nav.Pop()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.pruneList, cmd = m.pruneList.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) handlePackagesRemovedMsg(msg packagesRemovedMsg) (tea.Model, tea.Cmd) {
+	m.nav.Pop()
+
+	if msg.err != nil {
+		log.Printf("profiles: removing packages failed: %v", msg.err)
+		m.pruneSummary = fmt.Sprintf("Removing packages failed: %v", msg.err)
+		return m, nil
+	}
+
+	m.pruneSummary = fmt.Sprintf("Removed %d packages: %s", len(msg.packages), strings.Join(msg.packages, ", "))
+	return m, nil
+}
+
+// pruneSelection returns the packages ticked for removal.
+func (m *Model) pruneSelection() []string {
+	var remove []string
+	for _, item := range m.pruneList.Items() {
+		pkg := item.(packageItem).name
+		if !m.pruneDeselected[pkg] {
+			remove = append(remove, pkg)
+		}
+	}
+
+	return remove
+}
+
+func (m *Model) pruneView() string {
+	remove := m.pruneSelection()
+	header := styles.TitleStyle.Render("Packages not in this profile")
+	summary := fmt.Sprintf(
+		"%d of %d ticked for removal (the package manager asks before removing anything)",
+		len(remove),
+		len(m.pruneList.Items()),
+	)
+	help := styles.SubtleTextStyle.Render(
+		"Space: toggle · /: search · Enter: remove · Esc: back",
+	)
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		summary,
+		styles.BlurredBorderStyle.Render(m.pruneList.View()),
+		help,
+	)
+}
@@ -0,0 +1,123 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"archsetup/internal/types"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestService_ArchPruneCandidates(t *testing.T) {
+	t.Run("it returns packages missing from the profile and the keep list", func(t *testing.T) {
+		mockExec := &mockExecutor{outputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+			switch cmd.Args[1] {
+			case "-Qqe":
+				return []byte("base\nsudo\ngrub\nnetworkmanager\ngit\nblender\nsteam\ngcc\nmake\nhtop\n"), nil
+			case "-Sgq":
+				return []byte("gcc\nmake\n"), errors.New("exit status 1")
+			}
+			return nil, errors.New("unexpected command")
+		}}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		got, err := service.archPruneCandidates(
+			[]string{"git", "base-devel", "steam"},
+			[]string{"htop"},
+		)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !reflect.DeepEqual(got, []string{"blender"}) {
+			t.Errorf("Expected only blender, but got %v", got)
+		}
+	})
+
+	t.Run("it returns an error if the installed packages cannot be listed", func(t *testing.T) {
+		mockExec := &mockExecutor{outputErr: errors.New("no pacman")}
+		service := setupService(mockExec, &mockFileSystem{})
+
+		if _, err := service.archPruneCandidates(nil, nil); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestService_RemovePackagesCommand(t *testing.T) {
+	service := setupService(&mockExecutor{}, &mockFileSystem{})
+	if err := service.UsePackageManager("pacman", pkgmgr.Config{}); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	cmd, err := service.RemovePackagesCommand([]string{"blender", "steam"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	// No --noconfirm: pacman asks before removing anything.
+	if got := strings.Join(cmd.Args, " "); got != "sudo pacman -Rns blender steam" {
+		t.Errorf("Unexpected command %q", got)
+	}
+}
+
+func TestModel_Prune(t *testing.T) {
+	setup := func() *Model {
+		service := setupService(&mockExecutor{}, &mockFileSystem{})
+		m := New(types.DefaultKeys(), service, nil).(*Model)
+		m.nav.Push(installCompletePhase)
+		m.nav.Push(findingPrunePhase)
+		return m
+	}
+
+	t.Run("nothing is removed until it is ticked", func(t *testing.T) {
+		m := setup()
+		m.Update(pruneCandidatesMsg{packages: []string{"blender", "steam"}})
+
+		if got := m.pruneSelection(); len(got) != 0 {
+			t.Errorf("Expected nothing ticked, got %v", got)
+		}
+
+		m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+
+		if got := m.pruneSelection(); !reflect.DeepEqual(got, []string{"blender"}) {
+			t.Errorf("Expected only blender to be removed, got %v", got)
+		}
+	})
+
+	t.Run("enter with nothing ticked goes back", func(t *testing.T) {
+		m := setup()
+		m.Update(pruneCandidatesMsg{packages: []string{"blender"}})
+
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+		if cmd != nil || m.nav.Current() != installCompletePhase {
+			t.Errorf("Expected to go back without removing, got phase %v", m.nav.Current())
+		}
+	})
+
+	t.Run("it reports when there is nothing to prune", func(t *testing.T) {
+		m := setup()
+
+		m.Update(pruneCandidatesMsg{})
+
+		if m.nav.Current() != installCompletePhase || m.pruneSummary == "" {
+			t.Errorf("Expected a summary on the final screen, got phase %v", m.nav.Current())
+		}
+	})
+
+	t.Run("it returns to the final screen after removing", func(t *testing.T) {
+		m := setup()
+		m.Update(pruneCandidatesMsg{packages: []string{"blender"}})
+
+		m.Update(packagesRemovedMsg{packages: []string{"blender"}})
+
+		if m.nav.Current() != installCompletePhase || !strings.Contains(m.pruneSummary, "blender") {
+			t.Errorf("Expected the removal summary, got %q in phase %v", m.pruneSummary, m.nav.Current())
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package profiles
+
+import (
+	"archsetup/internal/styles"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// retryOutputLines is how much of a failed install's output the retry
+// screen shows; the full output is available through the pager.
+const retryOutputLines = 12
+
+type packageFailure struct {
+	err    error
+	output string
+}
+
+func (m *Model) handleRetryKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.retryCursor > 0 {
+			m.retryCursor--
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.retryCursor < len(m.packagesFailed)-1 {
+			m.retryCursor++
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Enter):
+		return m.retryPackages(m.packagesFailed[m.retryCursor])
+
+	case key.Matches(msg, m.keys.RetryAll):
+		return m.retryPackages(m.packagesFailed...)
+
+	case key.Matches(msg, m.keys.OpenLog):
+		pkg := m.packagesFailed[m.retryCursor]
+		return m, m.service.viewOutputCmd(pkg, m.failureOutput(pkg))
+
+	case key.Matches(msg, m.keys.Back):
+		log.Printf("profiles: skipping %d failed packages", len(m.packagesFailed))
+		return m.finishInstall()
+	}
+
+	return m, nil
+}
+
+// retryPackages takes packages off the failed list and installs them again,
+// one by one.
+func (m *Model) retryPackages(pkgs ...string) (tea.Model, tea.Cmd) {
+	log.Printf("profiles: retrying %s", strings.Join(pkgs, ", "))
+
+	retry := slices.Clone(pkgs)
+	m.packagesFailed = slices.DeleteFunc(m.packagesFailed, func(pkg string) bool {
+		return slices.Contains(retry, pkg)
+	})
+	for _, pkg := range retry {
+		delete(m.failures, pkg)
+	}
+
+	m.installQueue = retry
+	m.nav.Reset(installingPackagesPhase)
+	return m.installNext()
+}
+
+func (m *Model) failureOutput(pkg string) string {
+	failure, ok := m.failures[pkg]
+	if !ok {
+		return "No output captured; the package was retried from the previous run."
+	}
+	if strings.TrimSpace(failure.output) == "" {
+		return failure.err.Error()
+	}
+
+	return failure.output
+}
+
+func (m *Model) retryView() string {
+	header := styles.TitleStyle.Render(
+		fmt.Sprintf("%d packages failed to install", len(m.packagesFailed)),
+	)
+
+	var list strings.Builder
+	for i, pkg := range m.packagesFailed {
+		if i == m.retryCursor {
+			list.WriteString(styles.TitleStyle.Render("» "+pkg) + "\n")
+		} else {
+			list.WriteString(styles.NormalTextStyle.Render("  "+pkg) + "\n")
+		}
+	}
+
+	selected := m.packagesFailed[m.retryCursor]
+	output := strings.Split(strings.TrimRight(m.failureOutput(selected), "\n"), "\n")
+	if len(output) > retryOutputLines {
+		output = output[len(output)-retryOutputLines:]
+	}
+
+	help := styles.SubtleTextStyle.Render(
+		"Enter: retry · a: retry all · l: open log · Esc: skip",
+	)
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		"",
+		list.String(),
+		styles.SubtleTextStyle.Render("Output of "+selected+":"),
+		styles.BlurredBorderStyle.Width(m.width-2).Render(strings.Join(output, "\n")),
+		help,
+	)
+}
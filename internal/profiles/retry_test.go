@@ -0,0 +1,77 @@
+package profiles
+
+import (
+	"archsetup/internal/types"
+	"errors"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func setupRetryModel(failed ...string) *Model {
+	service := setupService(&mockExecutor{}, &mockFileSystem{})
+	m := New(types.DefaultKeys(), service, nil).(*Model)
+
+	m.toInstall = append([]string{"git"}, failed...)
+	m.packagesSucceeded = []string{"git"}
+	m.packagesFailed = failed
+	m.failures = map[string]packageFailure{}
+	for _, pkg := range failed {
+		m.failures[pkg] = packageFailure{err: errors.New("exit status 1"), output: "404 " + pkg}
+	}
+	m.nav.Push(installingPackagesPhase)
+	m.nav.Push(retryFailedPhase)
+
+	return m
+}
+
+func TestModel_RetryFailedPackages(t *testing.T) {
+	t.Run("Enter retries only the selected package", func(t *testing.T) {
+		m := setupRetryModel("spotify", "slack")
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+		if m.nav.Current() != installingPackagesPhase {
+			t.Errorf("Expected the installing phase, got %v", m.nav.Current())
+		}
+		if m.installing != "slack" {
+			t.Errorf("Expected slack to be reinstalled, got %q", m.installing)
+		}
+		if !reflect.DeepEqual(m.packagesFailed, []string{"spotify"}) {
+			t.Errorf("Expected spotify to stay failed, got %v", m.packagesFailed)
+		}
+	})
+
+	t.Run("a retries every failed package", func(t *testing.T) {
+		m := setupRetryModel("spotify", "slack")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+
+		if len(m.packagesFailed) != 0 {
+			t.Errorf("Expected no failed packages while retrying, got %v", m.packagesFailed)
+		}
+		if m.installing != "spotify" || !reflect.DeepEqual(m.installQueue, []string{"slack"}) {
+			t.Errorf("Expected spotify then slack, got %q then %v", m.installing, m.installQueue)
+		}
+	})
+
+	t.Run("Esc skips to the summary", func(t *testing.T) {
+		m := setupRetryModel("spotify")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+
+		if m.nav.Current() != installCompletePhase {
+			t.Errorf("Expected the summary, got %v", m.nav.Current())
+		}
+	})
+
+	t.Run("it shows the captured output of the selected package", func(t *testing.T) {
+		m := setupRetryModel("spotify")
+
+		if got := m.failureOutput("spotify"); got != "404 spotify" {
+			t.Errorf("Expected the captured output, got %q", got)
+		}
+	})
+}
@@ -1,22 +1,400 @@
 package profiles
 
+import (
+	"archsetup/internal/pkgmgr"
+	"archsetup/internal/system"
+	"archsetup/internal/utils"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+)
+
 type PostInstallCommand struct {
 	Description string `toml:"description"`
 	Command     string `toml:"command"`
 	WorkingDir  string `toml:"working_dir"`
 }
 
+// Render expands Go templates in the command and working directory, e.g.
+// "./bootstrap.sh --gpu {{ join .Facts.GPUVendors \",\" }}". The machine is
+// the template data.
+func (c PostInstallCommand) Render(m Machine) (PostInstallCommand, error) {
+	var err error
+	if c.Command, err = renderTemplate("command", c.Command, m); err != nil {
+		return PostInstallCommand{}, err
+	}
+	if c.WorkingDir, err = renderTemplate("working_dir", c.WorkingDir, m); err != nil {
+		return PostInstallCommand{}, err
+	}
+
+	return c, nil
+}
+
+func renderTemplate(name, text string, data any) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	return b.String(), nil
+}
+
 type Profile struct {
-	Name        string              `toml:"name"`
-	Description string              `toml:"description"`
-	Path        string              `toml:"path"`
-	OsFamily    string              `toml:"os_family"`
-	OsDistro    string              `toml:"os_distro"`
-	StowDirs    []string            `toml:"stow_dirs"`
-	Roles       []string            `toml:"roles"`
-	PostInstall *PostInstallCommand `toml:"post_install"`
+	Name           string               `toml:"name"`
+	Extends        string               `toml:"extends"`
+	Description    string               `toml:"description"`
+	Path           string               `toml:"path"`
+	Paths          []string             `toml:"paths"`
+	Brewfile       string               `toml:"brewfile"`
+	OsFamily       string               `toml:"os_family"`
+	OsDistro       string               `toml:"os_distro"`
+	OsLike         string               `toml:"os_like"`
+	OsVersion      string               `toml:"os_version"`
+	PackageManager string               `toml:"package_manager"`
+	Apt            pkgmgr.AptConfig     `toml:"apt"`
+	Dnf            pkgmgr.DnfConfig     `toml:"dnf"`
+	Brew           pkgmgr.BrewConfig    `toml:"brew"`
+	Flatpak        pkgmgr.FlatpakConfig `toml:"flatpak"`
+	StowDirs       []string             `toml:"stow_dirs"`
+	Roles          []string             `toml:"roles"`
+	Keep           []string             `toml:"keep"`
+	When           map[string]string    `toml:"when"`
+	Defaults       []MacDefault         `toml:"defaults"`
+	Tools          Tools                `toml:"tools"`
+	PostInstall    *PostInstallCommand  `toml:"post_install"`
+
+	// packageLists are the package lists of the profile and the profiles it
+	// extends, parents first. Set when the config is loaded.
+	packageLists []PackageList
+}
+
+// PackageList is a package list file of a profile.
+type PackageList struct {
+	// Path is relative to the dotfiles repo.
+	Path string
+	// Brewfile is set for the profile's brewfile, which is read as a
+	// Brewfile whatever it is called.
+	Brewfile bool
+}
+
+// Tools is a profile's [profiles.tools] table: command-line tools installed
+// with a language's own installer once the system packages are in.
+type Tools struct {
+	Cargo []string `toml:"cargo"`
+	Go    []string `toml:"go"`
+	Npm   []string `toml:"npm"`
+	Pipx  []string `toml:"pipx"`
+}
+
+// Entries returns the tools as package list entries, e.g. "cargo:ripgrep".
+func (t Tools) Entries() []string {
+	var entries []string
+	for _, tools := range []struct {
+		prefix string
+		names  []string
+	}{
+		{SourceCargo, t.Cargo},
+		{SourceGo, t.Go},
+		{SourceNpm, t.Npm},
+		{SourcePip, t.Pipx},
+	} {
+		for _, name := range tools.names {
+			entries = append(entries, tools.prefix+":"+name)
+		}
+	}
+
+	return entries
+}
+
+// InstallBatch is a group of packages installed in a single package manager
+// transaction.
+type InstallBatch struct {
+	Source   string
+	Packages []string
+}
+
+// Package sources.
+const (
+	SourceRepo    = "repo"
+	SourceAUR     = "aur"
+	SourceFlatpak = "flatpak"
+	SourceCask    = "cask"
+	SourcePip     = "pip"
+	SourceCargo   = "cargo"
+	SourceGo      = "go"
+	SourceNpm     = "npm"
+	SourceMas     = "mas"
+	SourceUnknown = "unknown"
+)
+
+// PackageSets splits a package list by where the packages come from.
+type PackageSets struct {
+	Repo    []string
+	AUR     []string
+	Unknown []string
 }
 
 type Config struct {
 	Profiles []Profile `toml:"profiles"`
+	// Keep lists packages prune mode never removes, for every profile.
+	Keep []string `toml:"keep"`
+}
+
+// MatchesOS reports whether the profile applies to the given OS. Empty
+// os_family / os_distro / os_like / os_version values match everything;
+// os_like also matches derivatives that list the distro in ID_LIKE.
+func (p Profile) MatchesOS(info system.OSInfo) bool {
+	famOk := p.OsFamily == "" || p.OsFamily == info.Family
+	distOk := p.OsDistro == "" || p.OsDistro == info.Distro
+	likeOk := p.OsLike == "" || info.IsLike(p.OsLike)
+
+	version, err := parseVersionConstraint(p.OsVersion)
+	versionOk := err == nil && version.Matches(info.Version)
+
+	return famOk && distOk && likeOk && versionOk
+}
+
+// PostInstallEnv returns the extra environment handed to the post-install
+// command: the profile's roles and the BAS_* machine facts.
+func (p Profile) PostInstallEnv(facts system.Facts) map[string]string {
+	roles := make([]string, 0, len(p.Roles))
+	for _, r := range p.Roles {
+		r = strings.TrimSpace(r)
+		if r != "" {
+			roles = append(roles, r)
+		}
+	}
+
+	env := facts.Env()
+	env["MACHINE_PROFILES"] = strings.Join(roles, ",")
+	return env
+}
+
+// Machine returns the machine the profile's conditions are evaluated
+// against.
+func (p Profile) Machine(facts system.Facts) Machine {
+	return Machine{Roles: p.Roles, Facts: facts}
+}
+
+// MatchesMachine reports whether the profile's [profiles.when] table holds
+// on the machine.
+func (p Profile) MatchesMachine(m Machine) bool {
+	cond, err := conditionFromTable(p.When)
+	return err == nil && cond.Matches(m)
+}
+
+// StowDirsFor returns the stow_dirs whose conditions hold on the machine,
+// with the conditions stripped.
+func (p Profile) StowDirsFor(m Machine) []string {
+	var dirs []string
+	for _, entry := range p.StowDirs {
+		dir, cond, err := splitCondition(entry)
+		if err == nil && dir != "" && cond.Matches(m) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// ProfilesFor returns the profiles that apply to the given OS and hardware,
+// in file order. Their stow_dirs only hold the directories whose conditions
+// match.
+func (c Config) ProfilesFor(info system.OSInfo, facts system.Facts) []Profile {
+	var matching []Profile
+	for _, p := range c.Profiles {
+		m := p.Machine(facts)
+		if p.MatchesOS(info) && p.MatchesMachine(m) {
+			p.StowDirs = p.StowDirsFor(m)
+			matching = append(matching, p)
+		}
+	}
+
+	return matching
+}
+
+// validate reports the first malformed os_version, package_manager, backend
+// setting, macOS default, condition in the profiles' when tables and
+// stow_dirs, or template in their post_install.
+func (c Config) validate() error {
+	for _, p := range c.Profiles {
+		if _, err := parseVersionConstraint(p.OsVersion); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+		if p.PackageManager != "" {
+			if _, err := pkgmgr.New(p.PackageManager, nil, pkgmgr.Config{}); err != nil {
+				return fmt.Errorf("profile %q: %w", p.Name, err)
+			}
+		}
+		if err := p.Backends().Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+		if p.PostInstall != nil {
+			if _, err := p.PostInstall.Render(Machine{}); err != nil {
+				return fmt.Errorf("profile %q: post_install: %w", p.Name, err)
+			}
+		}
+		if _, err := conditionFromTable(p.When); err != nil {
+			return fmt.Errorf("profile %q: when: %w", p.Name, err)
+		}
+		for _, d := range p.Defaults {
+			if err := d.validate(); err != nil {
+				return fmt.Errorf("profile %q: %w", p.Name, err)
+			}
+		}
+		for _, entry := range p.StowDirs {
+			if _, _, err := splitCondition(entry); err != nil {
+				return fmt.Errorf("profile %q: stow_dirs %q: %w", p.Name, entry, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Backends returns the profile's settings for the package manager backends.
+func (p Profile) Backends() pkgmgr.Config {
+	return pkgmgr.Config{Apt: p.Apt, Dnf: p.Dnf, Brew: p.Brew, Flatpak: p.Flatpak}
+}
+
+// PackageLists returns every package list the profile installs: path, paths,
+// then brewfile, after the ones inherited through extends.
+func (p Profile) PackageLists() []PackageList {
+	if p.packageLists != nil {
+		return p.packageLists
+	}
+
+	var own []PackageList
+	for _, path := range utils.MergeUnique(nil, slices.Concat([]string{p.Path}, p.Paths)) {
+		if path != "" {
+			own = append(own, PackageList{Path: path})
+		}
+	}
+	if p.Brewfile != "" {
+		own = utils.MergeUnique(own, []PackageList{{Path: p.Brewfile, Brewfile: true}})
+	}
+
+	return own
+}
+
+// resolveInheritance merges every profile with the profiles it extends.
+func (c *Config) resolveInheritance() error {
+	byName := make(map[string]Profile, len(c.Profiles))
+	for _, p := range c.Profiles {
+		byName[p.Name] = p
+	}
+
+	resolved := make([]Profile, len(c.Profiles))
+	for i, p := range c.Profiles {
+		r, err := resolveProfile(p, byName, nil)
+		if err != nil {
+			return err
+		}
+		resolved[i] = r
+	}
+
+	c.Profiles = resolved
+	return nil
+}
+
+// resolveProfile merges p with its ancestors. chain holds the profiles
+// currently being resolved, to detect cycles.
+func resolveProfile(
+	p Profile,
+	byName map[string]Profile,
+	chain []string,
+) (Profile, error) {
+	if slices.Contains(chain, p.Name) {
+		cycle := slices.Concat(chain, []string{p.Name})
+		return Profile{}, fmt.Errorf(
+			"profile inheritance cycle: %s",
+			strings.Join(cycle[slices.Index(cycle, p.Name):], " -> "),
+		)
+	}
+
+	p.packageLists = p.PackageLists()
+	if p.Extends == "" {
+		return p, nil
+	}
+
+	parent, ok := byName[p.Extends]
+	if !ok {
+		return Profile{}, fmt.Errorf(
+			"profile %q extends %q, which does not exist",
+			p.Name,
+			p.Extends,
+		)
+	}
+
+	parent, err := resolveProfile(parent, byName, slices.Concat(chain, []string{p.Name}))
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return mergeProfiles(parent, p), nil
+}
+
+// mergeProfiles applies child on top of parent. Lists, including the
+// backends' repositories and macOS defaults, are combined, parent entries
+// first and without duplicates; a scalar or post_install block set
+// on the child replaces the parent's, and when tables are merged key by key.
+// Name and description are never inherited.
+func mergeProfiles(parent, child Profile) Profile {
+	merged := child
+
+	if merged.OsFamily == "" {
+		merged.OsFamily = parent.OsFamily
+	}
+	if merged.OsDistro == "" {
+		merged.OsDistro = parent.OsDistro
+	}
+	if merged.OsLike == "" {
+		merged.OsLike = parent.OsLike
+	}
+	if merged.OsVersion == "" {
+		merged.OsVersion = parent.OsVersion
+	}
+	if merged.PackageManager == "" {
+		merged.PackageManager = parent.PackageManager
+	}
+	if merged.PostInstall == nil {
+		merged.PostInstall = parent.PostInstall
+	}
+
+	if len(parent.When) > 0 {
+		merged.When = make(map[string]string, len(parent.When)+len(child.When))
+		maps.Copy(merged.When, parent.When)
+		maps.Copy(merged.When, child.When)
+	}
+
+	backends := parent.Backends().Merge(child.Backends())
+	merged.Apt, merged.Dnf, merged.Brew = backends.Apt, backends.Dnf, backends.Brew
+	merged.Flatpak = backends.Flatpak
+	merged.StowDirs = utils.MergeUnique(parent.StowDirs, child.StowDirs)
+	merged.Roles = utils.MergeUnique(parent.Roles, child.Roles)
+	merged.Keep = utils.MergeUnique(parent.Keep, child.Keep)
+	merged.Defaults = mergeDefaults(parent.Defaults, child.Defaults)
+	merged.Tools = Tools{
+		Cargo: utils.MergeUnique(parent.Tools.Cargo, child.Tools.Cargo),
+		Go:    utils.MergeUnique(parent.Tools.Go, child.Tools.Go),
+		Npm:   utils.MergeUnique(parent.Tools.Npm, child.Tools.Npm),
+		Pipx:  utils.MergeUnique(parent.Tools.Pipx, child.Tools.Pipx),
+	}
+	merged.packageLists = utils.MergeUnique(parent.packageLists, child.packageLists)
+
+	return merged
 }
@@ -0,0 +1,295 @@
+package profiles
+
+import (
+	"archsetup/internal/pkgmgr"
+	"archsetup/internal/system"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func decodeConfig(t *testing.T, data string) (Config, error) {
+	t.Helper()
+
+	var cfg Config
+	if err := toml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("invalid test config: %v", err)
+	}
+
+	return cfg, cfg.resolveInheritance()
+}
+
+func TestConfig_ResolveInheritance(t *testing.T) {
+	t.Run("it merges the parent into the child", func(t *testing.T) {
+		cfg, err := decodeConfig(t, `
+[[profiles]]
+name = "Base"
+path = "base.txt"
+os_family = "linux"
+stow_dirs = ["git", "zsh"]
+roles = ["dev"]
+
+[profiles.post_install]
+command = "./base.sh"
+
+[[profiles]]
+name = "Desktop"
+extends = "Base"
+path = "desktop.txt"
+stow_dirs = ["zsh", "hyprland"]
+roles = ["gaming"]
+`)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		desktop := cfg.Profiles[1]
+		if !reflect.DeepEqual(desktop.StowDirs, []string{"git", "zsh", "hyprland"}) {
+			t.Errorf("Unexpected stow dirs %v", desktop.StowDirs)
+		}
+		if !reflect.DeepEqual(desktop.Roles, []string{"dev", "gaming"}) {
+			t.Errorf("Unexpected roles %v", desktop.Roles)
+		}
+		if want := []PackageList{{Path: "base.txt"}, {Path: "desktop.txt"}}; !reflect.DeepEqual(desktop.PackageLists(), want) {
+			t.Errorf("Unexpected package lists %v", desktop.PackageLists())
+		}
+		if desktop.OsFamily != "linux" {
+			t.Errorf("Expected os_family to be inherited, got %q", desktop.OsFamily)
+		}
+		if desktop.PostInstall == nil || desktop.PostInstall.Command != "./base.sh" {
+			t.Errorf("Expected post_install to be inherited, got %+v", desktop.PostInstall)
+		}
+	})
+
+	t.Run("the child's post_install replaces the parent's", func(t *testing.T) {
+		cfg, err := decodeConfig(t, `
+[[profiles]]
+name = "Base"
+[profiles.post_install]
+command = "./base.sh"
+
+[[profiles]]
+name = "Server"
+extends = "Base"
+[profiles.post_install]
+command = "./server.sh"
+`)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if got := cfg.Profiles[1].PostInstall.Command; got != "./server.sh" {
+			t.Errorf("Expected the child's command, got %q", got)
+		}
+	})
+
+	t.Run("it resolves several levels", func(t *testing.T) {
+		cfg, err := decodeConfig(t, `
+[[profiles]]
+name = "Laptop"
+extends = "Desktop"
+path = "laptop.txt"
+
+[[profiles]]
+name = "Desktop"
+extends = "Base"
+path = "desktop.txt"
+
+[[profiles]]
+name = "Base"
+path = "base.txt"
+`)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []PackageList{{Path: "base.txt"}, {Path: "desktop.txt"}, {Path: "laptop.txt"}}
+		if got := cfg.Profiles[0].PackageLists(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("it reports a missing parent", func(t *testing.T) {
+		_, err := decodeConfig(t, `
+[[profiles]]
+name = "Desktop"
+extends = "Bsae"
+`)
+
+		if err == nil || !strings.Contains(err.Error(), `"Desktop" extends "Bsae"`) {
+			t.Errorf("Expected a missing parent error, got %v", err)
+		}
+	})
+
+	t.Run("it reports cycles", func(t *testing.T) {
+		_, err := decodeConfig(t, `
+[[profiles]]
+name = "A"
+extends = "B"
+
+[[profiles]]
+name = "B"
+extends = "A"
+`)
+
+		if err == nil || !strings.Contains(err.Error(), "A -> B -> A") {
+			t.Errorf("Expected a cycle error, got %v", err)
+		}
+	})
+}
+
+func TestProfile_PackageLists(t *testing.T) {
+	t.Run("it combines path and paths without duplicates", func(t *testing.T) {
+		p := Profile{Path: "common.txt", Paths: []string{"dev.txt", "common.txt", "gaming.txt"}}
+
+		want := []PackageList{{Path: "common.txt"}, {Path: "dev.txt"}, {Path: "gaming.txt"}}
+		if got := p.PackageLists(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("paths works without path", func(t *testing.T) {
+		p := Profile{Paths: []string{"dev.txt"}}
+
+		if got := p.PackageLists(); !reflect.DeepEqual(got, []PackageList{{Path: "dev.txt"}}) {
+			t.Errorf("Expected [dev.txt], got %v", got)
+		}
+	})
+
+	t.Run("it marks the brewfile whatever it is called", func(t *testing.T) {
+		p := Profile{Path: "mac.txt", Brewfile: "mac/bundle"}
+
+		want := []PackageList{{Path: "mac.txt"}, {Path: "mac/bundle", Brewfile: true}}
+		if got := p.PackageLists(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestProfile_MatchesOS(t *testing.T) {
+	cachy := system.OSInfo{Family: "linux", Distro: "cachyos", Like: []string{"arch"}}
+	ubuntu := system.OSInfo{Family: "linux", Distro: "ubuntu", Like: []string{"debian"}, Version: "24.04"}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		info    system.OSInfo
+		want    bool
+	}{
+		{"os_like matches a derivative", Profile{OsLike: "arch"}, cachy, true},
+		{"os_like matches the distro itself", Profile{OsLike: "ubuntu"}, ubuntu, true},
+		{"os_like rejects other families", Profile{OsLike: "arch"}, ubuntu, false},
+		{"os_distro stays exact", Profile{OsDistro: "arch"}, cachy, false},
+		{"a version range matches", Profile{OsLike: "debian", OsVersion: ">=22.04,<25"}, ubuntu, true},
+		{"a bare version must be equal", Profile{OsVersion: "24.04.0"}, ubuntu, true},
+		{"a version range rejects", Profile{OsVersion: ">24.04"}, ubuntu, false},
+		{"rolling releases have no version", Profile{OsVersion: ">=1"}, cachy, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.MatchesOS(tt.info); got != tt.want {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"24.04", "24.04", 0},
+		{"24.04", "24.4", 0},
+		{"22.04", "24.04", -1},
+		{"40", "39", 1},
+		{"10", "9", 1},
+		{"13", "13.1", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run("it compares "+tt.a+" and "+tt.b, func(t *testing.T) {
+			if got := compareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("Expected %d, but got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOSVersion(t *testing.T) {
+	cfg := Config{Profiles: []Profile{{Name: "Broken", OsVersion: ">="}}}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("Expected an error, but got nil")
+	}
+}
+
+func TestConfig_ValidatePackageManager(t *testing.T) {
+	t.Run("it accepts registered backends", func(t *testing.T) {
+		cfg := Config{Profiles: []Profile{{Name: "Arch", PackageManager: "paru"}}}
+
+		if err := cfg.validate(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+
+	t.Run("it rejects unknown backends", func(t *testing.T) {
+		cfg := Config{Profiles: []Profile{{Name: "Broken", PackageManager: "zypper"}}}
+
+		if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "Broken") {
+			t.Errorf("Expected an error naming the profile, but got %v", err)
+		}
+	})
+}
+
+func TestConfig_AptRepositories(t *testing.T) {
+	t.Run("it merges the parent's repositories into the child", func(t *testing.T) {
+		cfg, err := decodeConfig(t, `
+[[profiles]]
+name = "Server"
+path = "server.txt"
+
+[profiles.apt]
+ppas = ["ppa:git-core/ppa"]
+
+[[profiles.apt.repos]]
+name = "docker"
+source = "deb https://download.docker.com/linux/ubuntu noble stable"
+
+[[profiles]]
+name = "Workstation"
+extends = "Server"
+path = "workstation.txt"
+
+[profiles.apt]
+ppas = ["ppa:neovim-ppa/unstable"]
+
+[[profiles.apt.repos]]
+name = "docker"
+key = "https://download.docker.com/linux/ubuntu/gpg"
+source = "deb [signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable"
+`)
+
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		apt := cfg.Profiles[1].Apt
+		if !reflect.DeepEqual(apt.PPAs, []string{"ppa:git-core/ppa", "ppa:neovim-ppa/unstable"}) {
+			t.Errorf("Unexpected PPAs %v", apt.PPAs)
+		}
+		if len(apt.Repos) != 1 || apt.Repos[0].Key == "" {
+			t.Errorf("Expected the child's docker repository to win, got %+v", apt.Repos)
+		}
+	})
+
+	t.Run("it rejects PPAs without the ppa: prefix", func(t *testing.T) {
+		cfg := Config{Profiles: []Profile{{Name: "Broken", Apt: pkgmgr.AptConfig{PPAs: []string{"neovim-ppa/unstable"}}}}}
+
+		if err := cfg.validate(); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+}
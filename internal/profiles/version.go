@@ -0,0 +1,113 @@
+package profiles
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// versionOperators are the comparisons an os_version constraint can use,
+// longest first so ">=" is not read as ">".
+var versionOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// versionConstraint is a comma separated list of comparisons that must all
+// hold, e.g. ">=22.04,<25". A bare version means "=".
+type versionConstraint []versionComparison
+
+type versionComparison struct {
+	op      string
+	version string
+}
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var constraint versionConstraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		op := "="
+		for _, candidate := range versionOperators {
+			if rest, ok := strings.CutPrefix(part, candidate); ok {
+				op, part = candidate, strings.TrimSpace(rest)
+				break
+			}
+		}
+		if part == "" {
+			return nil, fmt.Errorf("invalid os_version %q: missing version after %s", s, op)
+		}
+
+		constraint = append(constraint, versionComparison{op: op, version: part})
+	}
+
+	return constraint, nil
+}
+
+// Matches reports whether version satisfies every comparison. An empty
+// version (rolling releases have no VERSION_ID) only satisfies an empty
+// constraint.
+func (c versionConstraint) Matches(version string) bool {
+	if len(c) == 0 {
+		return true
+	}
+	if version == "" {
+		return false
+	}
+
+	for _, cmp := range c {
+		n := compareVersions(version, cmp.version)
+		ok := false
+		switch cmp.op {
+		case "=":
+			ok = n == 0
+		case "!=":
+			ok = n != 0
+		case ">":
+			ok = n > 0
+		case ">=":
+			ok = n >= 0
+		case "<":
+			ok = n < 0
+		case "<=":
+			ok = n <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareVersions compares dotted versions segment by segment, numerically
+// where both segments are numbers. Missing segments count as zero, so
+// "24.04" equals "24.04.0".
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,116 @@
+package resume
+
+import (
+	"archsetup/internal/state"
+	"archsetup/internal/styles"
+	"archsetup/internal/types"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// AcceptedMsg is sent when the user chooses to resume the previous run.
+type AcceptedMsg struct {
+	State state.State
+}
+
+// DeclinedMsg is sent when the user chooses to start over.
+type DeclinedMsg struct{}
+
+type Model struct {
+	keys      types.KeyMap
+	state     state.State
+	selection bool
+	width     int
+	height    int
+}
+
+func New(keys types.KeyMap, st state.State) *Model {
+	return &Model{
+		keys:      keys,
+		state:     st,
+		selection: true,
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	m.selection = true
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.handleKeyMsg(msg)
+	}
+
+	return m, nil
+}
+
+func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up), key.Matches(msg, m.keys.Down):
+		m.selection = !m.selection
+		return m, nil
+
+	case key.Matches(msg, m.keys.Back):
+		return m, func() tea.Msg { return DeclinedMsg{} }
+
+	case key.Matches(msg, m.keys.Enter):
+		if m.selection {
+			st := m.state
+			return m, func() tea.Msg { return AcceptedMsg{State: st} }
+		}
+		return m, func() tea.Msg { return DeclinedMsg{} }
+	}
+
+	return m, nil
+}
+
+func (m *Model) View() string {
+	question := styles.TitleStyle.Render("Resume the previous run?")
+
+	done := len(m.state.Packages)
+	progress := fmt.Sprintf("%d packages done", done)
+	if m.state.PackageCount > 0 {
+		progress = fmt.Sprintf("%d of %d packages done", done, m.state.PackageCount)
+	}
+
+	details := fmt.Sprintf(
+		"Profile '%s' was not finished (%s).\nDotfiles: %s",
+		m.state.Profile,
+		progress,
+		m.state.DotfilesPath,
+	)
+
+	yes := "[ ] Resume where it stopped"
+	no := "[ ] Start over"
+	if m.selection {
+		yes = styles.TitleStyle.Render("[•] Resume where it stopped")
+	} else {
+		no = styles.TitleStyle.Render("[•] Start over")
+	}
+
+	options := lipgloss.JoinVertical(lipgloss.Top, yes, "   ", no)
+	help := styles.SubtleTextStyle.Render(
+		"Use ↑/↓ to select. Press Enter to confirm, Esc to start over.",
+	)
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		question,
+		"\n",
+		details,
+		"\n",
+		options,
+		"\n",
+		help,
+	)
+}
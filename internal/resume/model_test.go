@@ -0,0 +1,48 @@
+package resume
+
+import (
+	"archsetup/internal/state"
+	"archsetup/internal/types"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestModel_Update(t *testing.T) {
+	st := state.State{Profile: "Desk", DotfilesPath: "/dots"}
+
+	t.Run("Enter on Resume accepts with the saved state", func(t *testing.T) {
+		m := New(types.DefaultKeys(), st)
+
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		msg, ok := cmd().(AcceptedMsg)
+
+		if !ok {
+			t.Fatalf("expected AcceptedMsg, got %T", cmd())
+		}
+		if msg.State.Profile != "Desk" {
+			t.Errorf("expected the saved profile, got %q", msg.State.Profile)
+		}
+	})
+
+	t.Run("Enter on Start over declines", func(t *testing.T) {
+		m := New(types.DefaultKeys(), st)
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+		if _, ok := cmd().(DeclinedMsg); !ok {
+			t.Errorf("expected DeclinedMsg, got %T", cmd())
+		}
+	})
+
+	t.Run("Esc declines", func(t *testing.T) {
+		m := New(types.DefaultKeys(), st)
+
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+
+		if _, ok := cmd().(DeclinedMsg); !ok {
+			t.Errorf("expected DeclinedMsg, got %T", cmd())
+		}
+	})
+}
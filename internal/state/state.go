@@ -0,0 +1,177 @@
+package state
+
+import (
+	"archsetup/internal/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	PackageOK     = "ok"
+	PackageFailed = "failed"
+)
+
+// State is the progress of a run, persisted so it survives a quit, crash or
+// reboot.
+type State struct {
+	CompletedPhases []types.Phase     `json:"completed_phases,omitempty"`
+	DotfilesPath    string            `json:"dotfiles_path,omitempty"`
+	Profile         string            `json:"profile,omitempty"`
+	PackageCount    int               `json:"package_count,omitempty"`
+	Packages        map[string]string `json:"packages,omitempty"`
+	Deselected      []string          `json:"deselected,omitempty"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// IsCompleted reports whether the phase finished in a previous run.
+func (s State) IsCompleted(p types.Phase) bool {
+	return slices.Contains(s.CompletedPhases, p)
+}
+
+// Resumable reports whether a profile installation was started but never
+// finished.
+func (s State) Resumable() bool {
+	return s.Profile != "" &&
+		s.DotfilesPath != "" &&
+		!s.IsCompleted(types.ProfilesPhase)
+}
+
+// CompletePhase records a finished phase once.
+func (s *State) CompletePhase(p types.Phase) {
+	if !s.IsCompleted(p) {
+		s.CompletedPhases = append(s.CompletedPhases, p)
+	}
+}
+
+// StartProfile records a newly selected profile, dropping the package
+// outcomes of any other profile.
+func (s *State) StartProfile(name string) {
+	s.CompletedPhases = slices.DeleteFunc(s.CompletedPhases, func(p types.Phase) bool {
+		return p == types.ProfilesPhase
+	})
+	if s.Profile != name {
+		s.Profile = name
+		s.PackageCount = 0
+		s.Packages = nil
+		s.Deselected = nil
+	}
+}
+
+// RecordPackage stores the outcome of a single package install.
+func (s *State) RecordPackage(pkg string, err error) {
+	if s.Packages == nil {
+		s.Packages = map[string]string{}
+	}
+
+	s.Packages[pkg] = PackageOK
+	if err != nil {
+		s.Packages[pkg] = PackageFailed
+	}
+}
+
+// DefaultPath returns $XDG_STATE_HOME/bas/state.json, falling back to
+// ~/.local/state when XDG_STATE_HOME is unset.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not get user home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(dir, "bas", "state.json"), nil
+}
+
+// Store keeps the current State in memory and writes it to disk on every
+// change. A nil *Store is valid and persists nothing.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	state State
+}
+
+// Open loads the state file at path. A missing file yields an empty state.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Current returns a copy of the current state.
+func (s *Store) Current() State {
+	if s == nil {
+		return State{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.state
+	st.CompletedPhases = slices.Clone(s.state.CompletedPhases)
+	st.Deselected = slices.Clone(s.state.Deselected)
+	if s.state.Packages != nil {
+		st.Packages = make(map[string]string, len(s.state.Packages))
+		for k, v := range s.state.Packages {
+			st.Packages[k] = v
+		}
+	}
+	return st
+}
+
+// Update applies fn to the state and saves it. Save errors are logged, not
+// returned: losing the state file must never stop an installation.
+func (s *Store) Update(fn func(*State)) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.state)
+	s.state.UpdatedAt = time.Now().UTC()
+
+	if err := s.save(); err != nil {
+		log.Printf("state: could not save %s: %v", s.path, err)
+	}
+}
+
+// save writes the state atomically so a crash never leaves a torn file.
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
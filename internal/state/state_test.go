@@ -0,0 +1,91 @@
+package state
+
+import (
+	"archsetup/internal/types"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_UpdateAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bas", "state.json")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("expected no error opening a missing file, got %v", err)
+	}
+
+	store.Update(func(s *State) {
+		s.DotfilesPath = "/home/u/dots"
+		s.CompletePhase(types.DotfilesPhase)
+		s.CompletePhase(types.DotfilesPhase)
+		s.StartProfile("Desk")
+		s.RecordPackage("git", nil)
+		s.RecordPackage("blender", errors.New("boom"))
+	})
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("expected no error reopening, got %v", err)
+	}
+
+	st := reopened.Current()
+	if len(st.CompletedPhases) != 1 || !st.IsCompleted(types.DotfilesPhase) {
+		t.Errorf("unexpected completed phases: %v", st.CompletedPhases)
+	}
+	if st.Packages["git"] != PackageOK || st.Packages["blender"] != PackageFailed {
+		t.Errorf("unexpected package outcomes: %v", st.Packages)
+	}
+	if !st.Resumable() {
+		t.Error("expected an unfinished profile to be resumable")
+	}
+}
+
+func TestState_StartProfile(t *testing.T) {
+	st := State{Profile: "Desk", Packages: map[string]string{"git": PackageOK}, Deselected: []string{"vim"}}
+	st.CompletePhase(types.ProfilesPhase)
+
+	st.StartProfile("Desk")
+	if st.IsCompleted(types.ProfilesPhase) || len(st.Packages) != 1 || len(st.Deselected) != 1 {
+		t.Errorf("restarting the same profile should keep outcomes, got %+v", st)
+	}
+
+	st.StartProfile("Laptop")
+	if st.Profile != "Laptop" || st.Packages != nil || st.Deselected != nil {
+		t.Errorf("switching profiles should drop outcomes, got %+v", st)
+	}
+}
+
+func TestOpen_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path); err == nil {
+		t.Error("expected an error for a corrupt state file")
+	}
+}
+
+func TestStore_NilIsNoop(t *testing.T) {
+	var store *Store
+
+	store.Update(func(s *State) { s.Profile = "x" })
+
+	if store.Current().Profile != "" {
+		t.Error("expected a nil store to keep no state")
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/xdg-state")
+
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if path != "/tmp/xdg-state/bas/state.json" {
+		t.Errorf("unexpected path: %s", path)
+	}
+}
@@ -0,0 +1,403 @@
+package system
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Chassis kinds.
+const (
+	ChassisLaptop  = "laptop"
+	ChassisDesktop = "desktop"
+)
+
+// Virtualization kinds. Bare metal is reported as "".
+const (
+	VirtVM        = "vm"
+	VirtContainer = "container"
+	VirtWSL       = "wsl"
+)
+
+// Boot modes.
+const (
+	BootUEFI = "uefi"
+	BootBIOS = "bios"
+)
+
+// Facts describe the machine BAS is running on.
+type Facts struct {
+	OS             OSInfo   `json:"os"`
+	CPUVendor      string   `json:"cpu_vendor"` // "intel", "amd", "apple", or "" if unknown
+	CPUModel       string   `json:"cpu_model"`
+	GPUs           []GPU    `json:"gpus"`
+	GPUVendors     []string `json:"gpu_vendors"` // "nvidia", "amd", "intel", "apple"
+	Chassis        string   `json:"chassis"`     // ChassisLaptop, ChassisDesktop, or "" if unknown
+	Virtualization string   `json:"virtualization"`
+	Kernel         string   `json:"kernel"`  // release of the running kernel
+	Kernels        []string `json:"kernels"` // installed kernel packages
+	Init           string   `json:"init"`
+	BootMode       string   `json:"boot_mode"` // BootUEFI, BootBIOS, or "" if unknown (always on macOS)
+	MemoryBytes    uint64   `json:"memory_bytes"`
+	Disks          []Disk   `json:"disks"` // not collected on macOS
+}
+
+// GPU is a display controller on the PCI bus.
+type GPU struct {
+	Vendor string `json:"vendor"`
+	PCIID  string `json:"pci_id"` // vendor:device, e.g. "10de:2684"
+	Slot   string `json:"slot"`
+}
+
+// Disk is a physical block device.
+type Disk struct {
+	Name       string `json:"name"`
+	Model      string `json:"model,omitempty"`
+	SizeBytes  uint64 `json:"size_bytes"`
+	Rotational bool   `json:"rotational"`
+}
+
+// pciVendors maps PCI vendor IDs to GPU vendor names.
+var pciVendors = map[string]string{
+	"10de": "nvidia",
+	"1002": "amd",
+	"8086": "intel",
+}
+
+// laptopChassisTypes are the SMBIOS chassis types of portable machines:
+// portable, laptop, notebook, sub notebook, tablet, convertible and
+// detachable.
+var laptopChassisTypes = []int{8, 9, 10, 14, 30, 31, 32}
+
+// hypervisorVendors are DMI system vendors that only show up in VMs.
+var hypervisorVendors = []string{"qemu", "vmware", "innotek", "xen", "microsoft corporation", "parallels"}
+
+// virtualBlockDevices are /sys/block prefixes that are not physical disks.
+var virtualBlockDevices = []string{"loop", "ram", "zram", "dm-", "sr", "fd", "nbd", "md"}
+
+// CurrentFacts detects the facts of the machine BAS is running on.
+func CurrentFacts() Facts {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxFacts("/")
+	case "darwin":
+		return darwinFacts(commandOutput)
+	}
+
+	return Facts{OS: CurrentOSInfo()}
+}
+
+// linuxFacts reads the facts from /etc, /proc and /sys below root.
+func linuxFacts(root string) Facts {
+	facts := Facts{
+		OS:             linuxOSInfo(filepath.Join(root, "etc", "os-release")),
+		Chassis:        linuxChassis(root),
+		Virtualization: linuxVirtualization(root),
+		Kernel:         readTrimmed(filepath.Join(root, "proc", "sys", "kernel", "osrelease")),
+		Kernels:        linuxKernels(root),
+		Init:           readTrimmed(filepath.Join(root, "proc", "1", "comm")),
+		BootMode:       BootBIOS,
+		MemoryBytes:    linuxMemory(root),
+		Disks:          linuxDisks(root),
+	}
+
+	facts.CPUVendor, facts.CPUModel = linuxCPU(root)
+	facts.GPUs = linuxGPUs(root)
+	for _, gpu := range facts.GPUs {
+		if !slices.Contains(facts.GPUVendors, gpu.Vendor) {
+			facts.GPUVendors = append(facts.GPUVendors, gpu.Vendor)
+		}
+	}
+
+	if exists(filepath.Join(root, "sys", "firmware", "efi")) {
+		facts.BootMode = BootUEFI
+	}
+
+	return facts
+}
+
+func linuxCPU(root string) (vendor, model string) {
+	data, err := os.ReadFile(filepath.Join(root, "proc", "cpuinfo"))
+	if err != nil {
+		return "", ""
+	}
+
+	for line := range strings.Lines(string(data)) {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "vendor_id":
+			if vendor == "" {
+				vendor = cpuVendorName(value)
+			}
+		case "model name":
+			if model == "" {
+				model = value
+			}
+		}
+	}
+
+	return vendor, model
+}
+
+func cpuVendorName(vendorID string) string {
+	switch vendorID {
+	case "GenuineIntel":
+		return "intel"
+	case "AuthenticAMD":
+		return "amd"
+	}
+
+	return strings.ToLower(vendorID)
+}
+
+// linuxGPUs lists the display controllers (PCI class 0x03xxxx).
+func linuxGPUs(root string) []GPU {
+	devices, err := filepath.Glob(filepath.Join(root, "sys", "bus", "pci", "devices", "*"))
+	if err != nil {
+		return nil
+	}
+
+	var gpus []GPU
+	for _, dev := range devices {
+		if !strings.HasPrefix(readTrimmed(filepath.Join(dev, "class")), "0x03") {
+			continue
+		}
+
+		vendorID := strings.TrimPrefix(readTrimmed(filepath.Join(dev, "vendor")), "0x")
+		deviceID := strings.TrimPrefix(readTrimmed(filepath.Join(dev, "device")), "0x")
+
+		vendor, ok := pciVendors[vendorID]
+		if !ok {
+			vendor = vendorID
+		}
+		gpus = append(gpus, GPU{
+			Vendor: vendor,
+			PCIID:  vendorID + ":" + deviceID,
+			Slot:   filepath.Base(dev),
+		})
+	}
+
+	return gpus
+}
+
+func linuxChassis(root string) string {
+	chassisType, err := strconv.Atoi(readTrimmed(filepath.Join(root, "sys", "class", "dmi", "id", "chassis_type")))
+	if err == nil && slices.Contains(laptopChassisTypes, chassisType) {
+		return ChassisLaptop
+	}
+
+	// Not every laptop reports its chassis (e.g. ARM boards); a battery is
+	// the next best hint.
+	if batteries, _ := filepath.Glob(filepath.Join(root, "sys", "class", "power_supply", "BAT*")); len(batteries) > 0 {
+		return ChassisLaptop
+	}
+
+	// Types 1 and 2 are "other" and "unknown".
+	if err == nil && chassisType > 2 {
+		return ChassisDesktop
+	}
+
+	return ""
+}
+
+// linuxVirtualization tells WSL, containers and VMs apart from bare metal,
+// in that order: WSL and containers can also report a hypervisor.
+func linuxVirtualization(root string) string {
+	osRelease := strings.ToLower(readTrimmed(filepath.Join(root, "proc", "sys", "kernel", "osrelease")))
+	if strings.Contains(osRelease, "microsoft") {
+		return VirtWSL
+	}
+
+	if exists(filepath.Join(root, ".dockerenv")) || exists(filepath.Join(root, "run", ".containerenv")) {
+		return VirtContainer
+	}
+	cgroup := readTrimmed(filepath.Join(root, "proc", "1", "cgroup"))
+	for _, marker := range []string{"docker", "kubepods", "lxc", "containerd"} {
+		if strings.Contains(cgroup, marker) {
+			return VirtContainer
+		}
+	}
+
+	sysVendor := strings.ToLower(readTrimmed(filepath.Join(root, "sys", "class", "dmi", "id", "sys_vendor")))
+	for _, vendor := range hypervisorVendors {
+		if strings.HasPrefix(sysVendor, vendor) {
+			return VirtVM
+		}
+	}
+
+	cpuinfo, _ := os.ReadFile(filepath.Join(root, "proc", "cpuinfo"))
+	for line := range strings.Lines(string(cpuinfo)) {
+		if key, flags, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "flags" {
+			if slices.Contains(strings.Fields(flags), "hypervisor") {
+				return VirtVM
+			}
+			break
+		}
+	}
+
+	return ""
+}
+
+// linuxKernels lists the installed kernels. Arch records the package of
+// every kernel in /usr/lib/modules/<release>/pkgbase; elsewhere the release
+// is used.
+func linuxKernels(root string) []string {
+	dirs, err := filepath.Glob(filepath.Join(root, "usr", "lib", "modules", "*"))
+	if err != nil {
+		return nil
+	}
+
+	var kernels []string
+	for _, dir := range dirs {
+		name := readTrimmed(filepath.Join(dir, "pkgbase"))
+		if name == "" {
+			if !exists(filepath.Join(dir, "vmlinuz")) && !exists(filepath.Join(dir, "modules.dep")) {
+				continue
+			}
+			name = filepath.Base(dir)
+		}
+		if !slices.Contains(kernels, name) {
+			kernels = append(kernels, name)
+		}
+	}
+
+	return kernels
+}
+
+func linuxMemory(root string) uint64 {
+	data, err := os.ReadFile(filepath.Join(root, "proc", "meminfo"))
+	if err != nil {
+		return 0
+	}
+
+	for line := range strings.Lines(string(data)) {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			return kb * 1024
+		}
+	}
+
+	return 0
+}
+
+func linuxDisks(root string) []Disk {
+	devices, err := filepath.Glob(filepath.Join(root, "sys", "block", "*"))
+	if err != nil {
+		return nil
+	}
+
+	var disks []Disk
+	for _, dev := range devices {
+		name := filepath.Base(dev)
+		if slices.ContainsFunc(virtualBlockDevices, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		}) {
+			continue
+		}
+
+		// The size is always in 512-byte sectors, whatever the device uses.
+		sectors, err := strconv.ParseUint(readTrimmed(filepath.Join(dev, "size")), 10, 64)
+		if err != nil || sectors == 0 {
+			continue
+		}
+
+		disks = append(disks, Disk{
+			Name:       name,
+			Model:      readTrimmed(filepath.Join(dev, "device", "model")),
+			SizeBytes:  sectors * 512,
+			Rotational: readTrimmed(filepath.Join(dev, "queue", "rotational")) == "1",
+		})
+	}
+
+	return disks
+}
+
+// darwinFacts asks sysctl and pmset through output, which returns the
+// trimmed output of a command or "" if it fails. The boot mode and disks are
+// not collected: BAS never needs them on a Mac.
+func darwinFacts(output func(name string, args ...string) string) Facts {
+	sysctl := func(name string) string { return output("sysctl", "-n", name) }
+
+	facts := Facts{
+		OS:        CurrentOSInfo(),
+		CPUVendor: cpuVendorName(sysctl("machdep.cpu.vendor")),
+		Chassis:   ChassisDesktop,
+		Kernel:    sysctl("kern.osrelease"),
+		Init:      "launchd",
+		CPUModel:  sysctl("machdep.cpu.brand_string"),
+	}
+	// Apple silicon has no machdep.cpu.vendor. hw.optional.arm64 is set
+	// even when BAS runs under Rosetta.
+	if sysctl("hw.optional.arm64") == "1" {
+		facts.CPUVendor = "apple"
+		facts.GPUVendors = []string{"apple"}
+	}
+	// Only laptops have an internal battery; desktops list the AC power
+	// source alone.
+	if strings.Contains(output("pmset", "-g", "batt"), "InternalBattery") {
+		facts.Chassis = ChassisLaptop
+	}
+	facts.MemoryBytes, _ = strconv.ParseUint(sysctl("hw.memsize"), 10, 64)
+
+	return facts
+}
+
+func commandOutput(name string, args ...string) string {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// Env returns the facts as BAS_* environment variables for the
+// post-install command. BAS_FACTS holds all of them as JSON.
+func (f Facts) Env() map[string]string {
+	env := map[string]string{
+		"BAS_OS_FAMILY":      f.OS.Family,
+		"BAS_OS_DISTRO":      f.OS.Distro,
+		"BAS_OS_LIKE":        strings.Join(f.OS.Like, " "),
+		"BAS_OS_VERSION":     f.OS.Version,
+		"BAS_CPU_VENDOR":     f.CPUVendor,
+		"BAS_GPU_VENDORS":    strings.Join(f.GPUVendors, ","),
+		"BAS_CHASSIS":        f.Chassis,
+		"BAS_VIRTUALIZATION": f.Virtualization,
+		"BAS_KERNEL":         f.Kernel,
+		"BAS_KERNELS":        strings.Join(f.Kernels, ","),
+		"BAS_INIT":           f.Init,
+		"BAS_BOOT_MODE":      f.BootMode,
+		"BAS_MEMORY_BYTES":   strconv.FormatUint(f.MemoryBytes, 10),
+	}
+
+	if data, err := json.Marshal(f); err == nil {
+		env["BAS_FACTS"] = string(data)
+	}
+
+	return env
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,248 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the given files below root.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLinuxFacts(t *testing.T) {
+	t.Run("it reads the facts of a UEFI gaming laptop", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			"etc/os-release": "NAME=\"Arch Linux\"\nID=arch\n",
+			"proc/cpuinfo": "processor\t: 0\nvendor_id\t: AuthenticAMD\n" +
+				"model name\t: AMD Ryzen 7 7840HS\nflags\t\t: fpu vme sse\n",
+			"proc/meminfo":              "MemTotal:       32768000 kB\nMemFree:  1 kB\n",
+			"proc/sys/kernel/osrelease": "6.9.1-arch1-1\n",
+			"proc/1/comm":               "systemd\n",
+			// An Intel iGPU, an NVIDIA dGPU and a non-display NVIDIA device.
+			"sys/bus/pci/devices/0000:00:02.0/class":   "0x030000\n",
+			"sys/bus/pci/devices/0000:00:02.0/vendor":  "0x8086\n",
+			"sys/bus/pci/devices/0000:00:02.0/device":  "0xa7a0\n",
+			"sys/bus/pci/devices/0000:01:00.0/class":   "0x030200\n",
+			"sys/bus/pci/devices/0000:01:00.0/vendor":  "0x10de\n",
+			"sys/bus/pci/devices/0000:01:00.0/device":  "0x2684\n",
+			"sys/bus/pci/devices/0000:01:00.1/class":   "0x040300\n",
+			"sys/bus/pci/devices/0000:01:00.1/vendor":  "0x10de\n",
+			"sys/class/dmi/id/chassis_type":            "10\n",
+			"sys/firmware/efi/fw_platform_size":        "64\n",
+			"sys/block/nvme0n1/size":                   "1000215216\n",
+			"sys/block/nvme0n1/queue/rotational":       "0\n",
+			"sys/block/nvme0n1/device/model":           "Samsung SSD 980\n",
+			"sys/block/loop0/size":                     "1024\n",
+			"usr/lib/modules/6.9.1-arch1-1/pkgbase":    "linux\n",
+			"usr/lib/modules/6.6.30-1-lts/pkgbase":     "linux-lts\n",
+			"usr/lib/modules/extramodules-6.9-arch1/x": "",
+		})
+
+		facts := linuxFacts(root)
+
+		want := Facts{
+			OS:        OSInfo{Family: "linux", Distro: "arch"},
+			CPUVendor: "amd",
+			CPUModel:  "AMD Ryzen 7 7840HS",
+			GPUs: []GPU{
+				{Vendor: "intel", PCIID: "8086:a7a0", Slot: "0000:00:02.0"},
+				{Vendor: "nvidia", PCIID: "10de:2684", Slot: "0000:01:00.0"},
+			},
+			GPUVendors:  []string{"intel", "nvidia"},
+			Chassis:     ChassisLaptop,
+			Kernel:      "6.9.1-arch1-1",
+			Kernels:     []string{"linux-lts", "linux"},
+			Init:        "systemd",
+			BootMode:    BootUEFI,
+			MemoryBytes: 32768000 * 1024,
+			Disks: []Disk{
+				{Name: "nvme0n1", Model: "Samsung SSD 980", SizeBytes: 1000215216 * 512},
+			},
+		}
+		if !reflect.DeepEqual(facts, want) {
+			t.Errorf("Expected %+v, but got %+v", want, facts)
+		}
+	})
+
+	t.Run("it falls back to the battery when the chassis is unknown", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			"sys/class/dmi/id/chassis_type":        "2\n",
+			"sys/class/power_supply/BAT0/capacity": "80\n",
+		})
+
+		if got := linuxFacts(root).Chassis; got != ChassisLaptop {
+			t.Errorf("Expected %q, but got %q", ChassisLaptop, got)
+		}
+	})
+
+	t.Run("it reports a desktop chassis", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"sys/class/dmi/id/chassis_type": "3\n"})
+
+		if got := linuxFacts(root).Chassis; got != ChassisDesktop {
+			t.Errorf("Expected %q, but got %q", ChassisDesktop, got)
+		}
+	})
+}
+
+func TestDarwinFacts(t *testing.T) {
+	// fakeOutput answers the commands darwinFacts runs from outputs, keyed
+	// by the command line.
+	fakeOutput := func(outputs map[string]string) func(string, ...string) string {
+		return func(name string, args ...string) string {
+			return outputs[strings.Join(append([]string{name}, args...), " ")]
+		}
+	}
+
+	t.Run("it reads the CPU vendor of an Intel Mac", func(t *testing.T) {
+		facts := darwinFacts(fakeOutput(map[string]string{
+			"sysctl -n machdep.cpu.vendor": "GenuineIntel",
+			"sysctl -n hw.memsize":         "17179869184",
+		}))
+
+		if facts.CPUVendor != "intel" || facts.Chassis != ChassisDesktop || facts.MemoryBytes != 17179869184 {
+			t.Errorf("Expected an Intel desktop with 16 GiB, but got %+v", facts)
+		}
+		if facts.BootMode != "" || facts.Disks != nil {
+			t.Errorf("Expected the boot mode and disks to be unknown, but got %q and %v", facts.BootMode, facts.Disks)
+		}
+	})
+
+	t.Run("it detects Apple silicon", func(t *testing.T) {
+		facts := darwinFacts(fakeOutput(map[string]string{"sysctl -n hw.optional.arm64": "1"}))
+
+		if facts.CPUVendor != "apple" || !reflect.DeepEqual(facts.GPUVendors, []string{"apple"}) {
+			t.Errorf("Expected Apple CPU and GPU, but got %+v", facts)
+		}
+	})
+
+	t.Run("it detects a laptop by its battery", func(t *testing.T) {
+		facts := darwinFacts(fakeOutput(map[string]string{
+			"pmset -g batt": "Now drawing from 'AC Power'\n -InternalBattery-0 (id=4653155)\t100%; charged; 0:00 remaining present: true",
+		}))
+
+		if facts.Chassis != ChassisLaptop {
+			t.Errorf("Expected %q, but got %q", ChassisLaptop, facts.Chassis)
+		}
+	})
+}
+
+func TestLinuxVirtualization(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"bare metal", map[string]string{"proc/cpuinfo": "flags\t: fpu sse\n"}, ""},
+		{"a VM by DMI vendor", map[string]string{"sys/class/dmi/id/sys_vendor": "QEMU\n"}, VirtVM},
+		{"a VM by CPU flag", map[string]string{"proc/cpuinfo": "flags\t: fpu hypervisor\n"}, VirtVM},
+		{"a docker container", map[string]string{".dockerenv": "", "proc/cpuinfo": "flags\t: hypervisor\n"}, VirtContainer},
+		{"WSL", map[string]string{"proc/sys/kernel/osrelease": "5.15.153.1-microsoft-standard-WSL2\n"}, VirtWSL},
+	}
+
+	for _, tt := range tests {
+		t.Run("it detects "+tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			if got := linuxVirtualization(root); got != tt.want {
+				t.Errorf("Expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFacts_Env(t *testing.T) {
+	facts := Facts{
+		OS:         OSInfo{Family: "linux", Distro: "arch"},
+		GPUVendors: []string{"intel", "nvidia"},
+		Kernels:    []string{"linux", "linux-lts"},
+	}
+
+	env := facts.Env()
+
+	if env["BAS_GPU_VENDORS"] != "intel,nvidia" || env["BAS_KERNELS"] != "linux,linux-lts" {
+		t.Errorf("Expected comma separated lists, but got %v", env)
+	}
+	if !strings.Contains(env["BAS_FACTS"], `"distro":"arch"`) {
+		t.Errorf("Expected BAS_FACTS to hold the facts as JSON, but got %q", env["BAS_FACTS"])
+	}
+}
+
+func TestLinuxOSInfo(t *testing.T) {
+	t.Run("it parses a derivative's os-release", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"os-release": `NAME="CachyOS Linux"
+PRETTY_NAME="CachyOS"
+ID=cachyos
+ID_LIKE=arch
+BUILD_ID=rolling
+`})
+
+		info := linuxOSInfo(filepath.Join(root, "os-release"))
+
+		want := OSInfo{Family: "linux", Distro: "cachyos", Like: []string{"arch"}, PrettyName: "CachyOS"}
+		if !reflect.DeepEqual(info, want) {
+			t.Errorf("Expected %+v, but got %+v", want, info)
+		}
+		if !info.IsLike("arch") || info.IsLike("debian") {
+			t.Errorf("Expected CachyOS to be like arch only")
+		}
+		if !info.IsArchLike() {
+			t.Errorf("Expected CachyOS to be Arch-like")
+		}
+	})
+
+	t.Run("it knows Arch derivatives without ID_LIKE by their ID", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"os-release": "NAME=\"EndeavourOS\"\nID=endeavouros\n"})
+
+		info := linuxOSInfo(filepath.Join(root, "os-release"))
+
+		if !info.IsArchLike() {
+			t.Errorf("Expected %+v to be Arch-like", info)
+		}
+		if (OSInfo{Family: "linux", Distro: "ubuntu", Like: []string{"debian"}}).IsArchLike() {
+			t.Error("Expected Ubuntu not to be Arch-like")
+		}
+	})
+
+	t.Run("it parses versions and variants", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"os-release": `ID="ubuntu"
+ID_LIKE='debian'
+VERSION_ID="24.04"
+VARIANT_ID=server
+PRETTY_NAME="Ubuntu 24.04.1 LTS"
+`})
+
+		info := linuxOSInfo(filepath.Join(root, "os-release"))
+
+		want := OSInfo{
+			Family:     "linux",
+			Distro:     "ubuntu",
+			Like:       []string{"debian"},
+			Version:    "24.04",
+			Variant:    "server",
+			PrettyName: "Ubuntu 24.04.1 LTS",
+		}
+		if !reflect.DeepEqual(info, want) {
+			t.Errorf("Expected %+v, but got %+v", want, info)
+		}
+	})
+}
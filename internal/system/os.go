@@ -4,12 +4,39 @@ import (
 	"bufio"
 	"os"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 type OSInfo struct {
-	Family string
-	Distro string
+	Family     string   `json:"family"`
+	Distro     string   `json:"distro"`            // os-release ID
+	Like       []string `json:"like,omitempty"`    // os-release ID_LIKE
+	Version    string   `json:"version,omitempty"` // os-release VERSION_ID
+	Variant    string   `json:"variant,omitempty"` // os-release VARIANT_ID
+	PrettyName string   `json:"pretty_name,omitempty"`
+}
+
+// IsLike reports whether the OS is the given distro or derives from it,
+// e.g. CachyOS and Manjaro are like "arch".
+func (o OSInfo) IsLike(distro string) bool {
+	distro = strings.ToLower(distro)
+	return o.Distro == distro || slices.Contains(o.Like, distro)
+}
+
+// archDistros are Arch derivatives known by their ID, for releases whose
+// os-release does not set ID_LIKE=arch.
+var archDistros = []string{"arch", "archlinux", "manjaro", "endeavouros", "garuda", "archarm"}
+
+// IsArchLike reports whether the OS is Linux with pacman and the AUR: Arch
+// itself, a derivative that declares ID_LIKE=arch, or one of archDistros.
+func (o OSInfo) IsArchLike() bool {
+	if o.Family != "linux" {
+		return false
+	}
+
+	return o.IsLike("arch") || slices.Contains(archDistros, strings.ToLower(o.Distro))
 }
 
 func CurrentOSInfo() OSInfo {
@@ -17,9 +44,7 @@ func CurrentOSInfo() OSInfo {
 	info := OSInfo{Family: fam}
 
 	if fam == "linux" {
-		if id := linuxDistroID(); id != "" {
-			info.Distro = id
-		}
+		info = linuxOSInfo("/etc/os-release")
 	}
 	if fam == "darwin" {
 		info.Distro = "macos"
@@ -27,24 +52,49 @@ func CurrentOSInfo() OSInfo {
 	return info
 }
 
-func linuxDistroID() string {
-	f, err := os.Open("/etc/os-release")
+// linuxOSInfo reads the os-release file at path.
+func linuxOSInfo(path string) OSInfo {
+	info := OSInfo{Family: "linux"}
+
+	f, err := os.Open(path)
 	if err != nil {
-		return ""
+		return info
 	}
 	defer f.Close()
 
 	sc := bufio.NewScanner(f)
 	for sc.Scan() {
-		line := sc.Text()
-		if !strings.HasPrefix(line, "ID=") {
+		key, val, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
 			continue
 		}
+		val = unquoteOSRelease(val)
+
+		switch key {
+		case "ID":
+			info.Distro = strings.ToLower(val)
+		case "ID_LIKE":
+			info.Like = strings.Fields(strings.ToLower(val))
+		case "VERSION_ID":
+			info.Version = val
+		case "VARIANT_ID":
+			info.Variant = strings.ToLower(val)
+		case "PRETTY_NAME":
+			info.PrettyName = val
+		}
+	}
+	return info
+}
 
-		// ID=arch OR ID="arch"
-		val := strings.TrimPrefix(line, "ID=")
-		val = strings.Trim(val, `"'`)
-		return strings.ToLower(strings.TrimSpace(val))
+// unquoteOSRelease strips the shell-style quoting os-release values may use:
+// ID=arch, ID="arch" or ID='arch'.
+func unquoteOSRelease(val string) string {
+	val = strings.TrimSpace(val)
+	if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			return unquoted
+		}
 	}
-	return ""
+
+	return strings.Trim(val, `"'`)
 }
@@ -11,6 +11,10 @@ type KeyMap struct {
 	Back     key.Binding
 	Tab      key.Binding
 	ShiftTab key.Binding
+	Toggle   key.Binding
+	RetryAll key.Binding
+	OpenLog  key.Binding
+	Prune    key.Binding
 }
 
 func DefaultKeys() KeyMap {
@@ -47,6 +51,22 @@ func DefaultKeys() KeyMap {
 			key.WithKeys("shift+tab"),
 			key.WithHelp("shift+tab", "prev"),
 		),
+		Toggle: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "toggle"),
+		),
+		RetryAll: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "retry all"),
+		),
+		OpenLog: key.NewBinding(
+			key.WithKeys("l"),
+			key.WithHelp("l", "open log"),
+		),
+		Prune: key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", "prune"),
+		),
 	}
 	// TODO: Map G and gg to go to bottom / top
 }
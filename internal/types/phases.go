@@ -1,5 +1,7 @@
 package types
 
+import "fmt"
+
 type Phase int
 
 const (
@@ -8,6 +10,7 @@ const (
 	DotfilesPhase
 	NvidiaDriversPhase
 	ProfilesPhase
+	ResumePhase
 	DonePhase
 )
 
@@ -24,4 +27,50 @@ type PhaseCancelled struct {
 	Phase Phase
 }
 
+// PhaseFailed stops an unattended run when a phase fails, since nobody is
+// there to pick another way forward.
+type PhaseFailed struct {
+	Phase Phase
+	Err   error
+}
+
 type PhaseBack struct{}
+
+// String returns the phase name used in logs and the JSON event stream.
+func (p Phase) String() string {
+	switch p {
+	case MenuPhase:
+		return "menu"
+	case GithubAuthPhase:
+		return "github_auth"
+	case DotfilesPhase:
+		return "dotfiles"
+	case NvidiaDriversPhase:
+		return "nvidia_drivers"
+	case ProfilesPhase:
+		return "profiles"
+	case ResumePhase:
+		return "resume"
+	case DonePhase:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the phase by name so persisted state stays readable.
+func (p Phase) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a phase name written by MarshalText.
+func (p *Phase) UnmarshalText(text []byte) error {
+	for candidate := MenuPhase; candidate <= DonePhase; candidate++ {
+		if candidate.String() == string(text) {
+			*p = candidate
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown phase: %q", string(text))
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home dir available")
+	}
+
+	got, err := ExpandHome("~/Developer/dotfiles")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := filepath.Join(home, "Developer", "dotfiles"); got != want {
+		t.Errorf("ExpandHome() = %q, want %q", got, want)
+	}
+
+	if got, _ := ExpandHome("/abs/path"); got != "/abs/path" {
+		t.Errorf("expected absolute paths to be unchanged, got %q", got)
+	}
+}
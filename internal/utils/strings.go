@@ -1,6 +1,9 @@
 package utils
 
-import "strings"
+import (
+	"slices"
+	"strings"
+)
 
 func PadRightToSameLength(strs ...*string) {
 	if len(strs) < 2 {
@@ -20,3 +23,17 @@ func PadRightToSameLength(strs ...*string) {
 		}
 	}
 }
+
+// MergeUnique appends the child's entries to the parent's, skipping
+// duplicates. Profiles and their package manager settings merge lists this
+// way when a child profile extends a parent.
+func MergeUnique[T comparable](parent, child []T) []T {
+	var merged []T
+	for _, item := range slices.Concat(parent, child) {
+		if !slices.Contains(merged, item) {
+			merged = append(merged, item)
+		}
+	}
+
+	return merged
+}